@@ -2,18 +2,43 @@ package app
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/TravisS25/cdbm/cdbmutil"
 	"github.com/TravisS25/webutil/webutil"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	// CDBM_CONFIG is the default enviroment variable that should point to config file
 	CDBM_CONFIG = "CDBM_CONFIG"
+
+	// DATABASE_URL is enviroment variable that can hold dsn of database to connect
+	// to if --dsn is not set
+	DATABASE_URL = "DATABASE_URL"
+)
+
+const (
+	// schemaMigrationsTable is table golang-migrate keeps current version in
+	schemaMigrationsTable = "schema_migrations"
+
+	// schemaMigrationsAppliedTable is table cdbm records applied versions in
+	schemaMigrationsAppliedTable = "schema_migrations_applied"
+
+	// schemaMigrationsSkippedTable is table cdbm records skipped versions in
+	schemaMigrationsSkippedTable = "schema_migrations_skipped"
 )
 
+// cdbmTables are tables managed by cdbm and golang-migrate in database of user
+var cdbmTables = []string{
+	schemaMigrationsTable,
+	schemaMigrationsAppliedTable,
+	schemaMigrationsSkippedTable,
+}
+
 // CDBM is main struct for app and is used for all commands
 type CDBM struct {
 	// DB is database connection used
@@ -39,15 +64,72 @@ type CDBM struct {
 	// LogFlags represents the flags for log command
 	LogFlags LogFlagsConfig `yaml:"log_flags" mapstructure:"log_flags"`
 
+	// DiffFlags represents the flags for diff, squash and verify-reversible commands
+	DiffFlags DiffFlagsConfig `yaml:"diff_flags" mapstructure:"diff_flags"`
+
 	// DatabaseConfig is map with different db connections to database to be used
 	// if one or more fail
-	DatabaseConfig map[string][]webutil.DatabaseSetting `yaml:"database_config" mapstructure:"database_config"`
+	DatabaseConfig map[string][]DatabaseSetting `yaml:"database_config" mapstructure:"database_config"`
+
+	// Targets is map of flag overrides keyed by the same names as DatabaseConfig
+	// which are applied when entry is selected with --target
+	Targets map[string]TargetConfig `yaml:"targets" mapstructure:"targets"`
+
+	// CustomMigrations are custom migrations used by commands that compare
+	// migrations against database but don't apply them such as CDBM#Status
+	//
+	// CDBM#Migrate takes its custom migrations as a parameter
+	CustomMigrations map[int]cdbmutil.CustomMigration `yaml:"-" mapstructure:"-"`
+
+	// Logger is used by every command to log what it does
+	//
+	// If not set, it will be set in NewCDBM to Logger that writes to stderr and
+	// LogFlagsConfig#LogFile based on RootFlagsConfig#LogLevel and RootFlagsConfig#LogFormat
+	Logger Logger `yaml:"-" mapstructure:"-"`
+
+	// Metrics records prometheus metrics of CDBM#Migrate if set
+	//
+	// Use NewMetrics with registerer of application so migrations ran within
+	// long running process are exposed along with its other metrics
+	Metrics *Metrics `yaml:"-" mapstructure:"-"`
+
+	// TracerProvider is used to record trace spans of CDBM#Migrate if set
+	TracerProvider trace.TracerProvider `yaml:"-" mapstructure:"-"`
 
 	// migrateCfg is config that is built as the CDBM#Migrate function is ran
 	migrateCfg migrateState
 
 	// currentDBSettings represents the current active connection to db
 	currentDBSettings webutil.DatabaseSetting
+
+	// connectDeadline is when RootFlagsConfig#ConnectTimeout runs out
+	connectDeadline time.Time
+
+	// passwords are passwords of settings used to connect to database which
+	// are redacted from errors and logs
+	passwords []string
+
+	// dsnFromEnv determines whether RootFlagsConfig#DSN was set by DATABASE_URL
+	dsnFromEnv bool
+
+	// skipVersions are versions skipped when migrating up along with versions
+	// with a tag of MigrateFlagsConfig#SkipTags
+	//
+	// Used by CDBM#Diff so scratch database leaves out same versions that
+	// were never applied to database it's compared with
+	skipVersions map[int]bool
+
+	// defaultLogger determines whether CDBM#Logger was set by CDBM#initLogger
+	defaultLogger bool
+
+	// logFile is log file CDBM#Logger writes to if set by CDBM#initLogger
+	logFile *rotatingFile
+
+	// logWriteErr is first error writing to log file
+	logWriteErr error
+
+	// logFilePath is LogFlagsConfig#LogFile that CDBM#Logger was built with
+	logFilePath string
 }
 
 func (cdbm *CDBM) GetCurrentDBSettings() webutil.DatabaseSetting {
@@ -59,6 +141,39 @@ func (cdbm *CDBM) GetCurrentDBSettings() webutil.DatabaseSetting {
 // RootFlagsConfig#DBProtocol is a required if not read from file
 // as it intiates what type of database we are working with
 func NewCDBM(cfg RootFlagsConfig, driverCfg interface{}) (*CDBM, error) {
+	cdbm, err := loadCDBM(cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return initCDBM(cdbm, driverCfg)
+}
+
+// LoadCDBM initiates a new *CDBM instance from config the same as NewCDBM
+// without connecting to database, for commands that only use scratch database
+// ie. squash
+func LoadCDBM(cfg RootFlagsConfig) (*CDBM, error) {
+	cdbm, err := loadCDBM(cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cdbm.prepare(); err != nil {
+		return nil, err
+	}
+
+	if err = cdbm.initLogger(); err != nil {
+		return nil, err
+	}
+
+	return cdbm, nil
+}
+
+// loadCDBM reads config with GetCDBMConfig and overrides its root flags
+// with the ones set in given cfg
+func loadCDBM(cfg RootFlagsConfig) (*CDBM, error) {
 	cdbm, err := GetCDBMConfig(cfg.EnvVar)
 
 	if err != nil {
@@ -84,6 +199,15 @@ func NewCDBM(cfg RootFlagsConfig, driverCfg interface{}) (*CDBM, error) {
 	if cfg.Password != "" {
 		cdbm.RootFlags.Password = cfg.Password
 	}
+	if cfg.PasswordFile != "" {
+		cdbm.RootFlags.PasswordFile = cfg.PasswordFile
+	}
+	if cfg.PasswordEnv != "" {
+		cdbm.RootFlags.PasswordEnv = cfg.PasswordEnv
+	}
+	if cfg.PasswordCommand != "" {
+		cdbm.RootFlags.PasswordCommand = cfg.PasswordCommand
+	}
 	if cfg.SSLMode != "" {
 		cdbm.RootFlags.SSLMode = cfg.SSLMode
 	}
@@ -102,6 +226,140 @@ func NewCDBM(cfg RootFlagsConfig, driverCfg interface{}) (*CDBM, error) {
 	if cfg.UseFileOnFail {
 		cdbm.RootFlags.UseFileOnFail = cfg.UseFileOnFail
 	}
+	if cfg.Target != "" {
+		cdbm.RootFlags.Target = cfg.Target
+	}
+	if cfg.DSN != "" {
+		cdbm.RootFlags.DSN = cfg.DSN
+	}
+	if cfg.ConnectRetries > 0 {
+		cdbm.RootFlags.ConnectRetries = cfg.ConnectRetries
+	}
+	if cfg.ConnectBackoff > 0 {
+		cdbm.RootFlags.ConnectBackoff = cfg.ConnectBackoff
+	}
+	if cfg.ConnectTimeout > 0 {
+		cdbm.RootFlags.ConnectTimeout = cfg.ConnectTimeout
+	}
+	if cfg.Session.MaxOpenConns > 0 {
+		cdbm.RootFlags.Session.MaxOpenConns = cfg.Session.MaxOpenConns
+	}
+	if cfg.Session.MaxIdleConns > 0 {
+		cdbm.RootFlags.Session.MaxIdleConns = cfg.Session.MaxIdleConns
+	}
+	if cfg.Session.ConnMaxLifetime > 0 {
+		cdbm.RootFlags.Session.ConnMaxLifetime = cfg.Session.ConnMaxLifetime
+	}
+	if cfg.LogLevel != "" {
+		cdbm.RootFlags.LogLevel = cfg.LogLevel
+	}
+	if cfg.LogFormat != "" {
+		cdbm.RootFlags.LogFormat = cfg.LogFormat
+	}
+	if len(cfg.Session.Params) > 0 {
+		if cdbm.RootFlags.Session.Params == nil {
+			cdbm.RootFlags.Session.Params = make(map[string]string)
+		}
+
+		for k, v := range cfg.Session.Params {
+			cdbm.RootFlags.Session.Params[k] = v
+		}
+	}
+
+	if dsn := cdbm.envDSN(); dsn != "" {
+		cdbm.RootFlags.DSN = dsn
+		cdbm.dsnFromEnv = true
+	}
+
+	return cdbm, nil
+}
+
+// envDSN returns DATABASE_URL enviroment variable if no other connection is
+// set by --dsn, --database, --host, --target or CDBM#DatabaseConfig so
+// DATABASE_URL exported for other tools neither conflicts with nor overrides
+// connection of cdbm
+func (cdbm *CDBM) envDSN() string {
+	if cdbm.RootFlags.DSN != "" ||
+		cdbm.RootFlags.Database != "" ||
+		cdbm.RootFlags.Host != "" ||
+		cdbm.RootFlags.Target != "" ||
+		len(cdbm.DatabaseConfig) > 0 {
+		return ""
+	}
+
+	return os.Getenv(DATABASE_URL)
+}
+
+// NewCDBMFromConfig initiates a new *CDBM instance from given config without
+// reading any config file or enviroment variables so cdbm can be embedded
+// in services that build their own config
+//
+// If CDBM#DB is already set, it is used instead of connecting to database
+//
+// MigrateFlagsConfig#TargetVersion of 0 is treated as unset and defaults to -1
+// so zero value config migrates to latest version instead of down to version 0.
+// To migrate down to version 0, set TargetVersion of returned *CDBM to 0
+func NewCDBMFromConfig(cfg CDBM, driverCfg interface{}) (*CDBM, error) {
+	cdbm := cfg
+
+	if cdbm.MigrateFlags.TargetVersion == 0 {
+		cdbm.MigrateFlags.TargetVersion = -1
+	}
+
+	return initCDBM(&cdbm, driverCfg)
+}
+
+// initCDBM applies target, protocol and connection settings of given *CDBM
+// and connects to database if CDBM#DB is not already set
+func initCDBM(cdbm *CDBM, driverCfg interface{}) (*CDBM, error) {
+	var err error
+
+	if err = cdbm.prepare(); err != nil {
+		return nil, err
+	}
+
+	if err = cdbm.initLogger(); err != nil {
+		return nil, err
+	}
+
+	// Connection settings are only used if database connection
+	// wasn't given through NewCDBMFromConfig
+	if cdbm.DB == nil {
+		settings, err := cdbm.connectionSettings()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err = cdbm.connect(settings); err != nil {
+			return nil, err
+		}
+	}
+
+	if cdbm.DB == nil {
+		return nil, fmt.Errorf("no connection to database was established.  Check config file or cli settings for proper settings")
+	}
+
+	if driverCfg != nil {
+		if _, err = cdbmutil.GetDatabaseDriver(
+			cdbm.DB.DB,
+			cdbm.DBProtocolCfg.DBProtocol,
+			driverCfg,
+		); err != nil {
+			return nil, errors.WithStack(cdbm.redactErr(err))
+		}
+
+		cdbm.DBProtocolCfg.DriverConfig = driverCfg
+	}
+
+	return cdbm, nil
+}
+
+// prepare applies target and protocol settings of *CDBM and verifies them
+func (cdbm *CDBM) prepare() error {
+	if err := cdbm.applyTarget(); err != nil {
+		return err
+	}
 
 	protocolSlice := make([]cdbmutil.DBProtocol, 0, len(cdbmutil.DefaultProtocolMap))
 
@@ -112,107 +370,117 @@ func NewCDBM(cfg RootFlagsConfig, driverCfg interface{}) (*CDBM, error) {
 	// If dbprotocol is not set by user either from file or cli, throw error
 	// as we need to to know which type of db we are connecting to
 	if cdbm.RootFlags.DBProtocol == "" {
-		return nil, fmt.Errorf("--db-protocol flag required.  Valid --db-protocol values are: %v", protocolSlice)
+		return fmt.Errorf("--db-protocol flag required.  Valid --db-protocol values are: %v", protocolSlice)
 	} else {
 		var ok bool
 
 		if cdbm.DBProtocolCfg, ok = cdbmutil.DefaultProtocolMap[cdbmutil.DBProtocol(cdbm.RootFlags.DBProtocol)]; !ok {
-			return nil, fmt.Errorf("invalid --db-protocol.  Valid --db-protocol values are: %v", protocolSlice)
+			return fmt.Errorf("invalid --db-protocol.  Valid --db-protocol values are: %v", protocolSlice)
 		}
 	}
 
-	// Searches for database connection based on config and returns error
-	// if one can't be established
-	searchConn := func() error {
-		foundConn := false
-
-		for _, conns := range cdbm.DatabaseConfig {
-			for _, conn := range conns {
-				cdbm.currentDBSettings = webutil.DatabaseSetting{
-					BaseAuthSetting: webutil.BaseAuthSetting{
-						Host:     conn.Host,
-						User:     conn.User,
-						Password: conn.Password,
-						Port:     conn.Port,
-					},
-					DBName:      conn.DBName,
-					SSLMode:     conn.SSLMode,
-					SSL:         conn.SSL,
-					SSLRootCert: conn.SSLRootCert,
-				}
-
-				if cdbm.DB, err = webutil.NewDB(
-					cdbm.currentDBSettings,
-					cdbm.DBProtocolCfg.DatabaseType,
-				); err == nil {
-					foundConn = true
-					break
-				}
-			}
-
-			if foundConn {
-				break
-			}
+	if cdbm.RootFlags.ConnectTimeout > 0 {
+		cdbm.connectDeadline = time.Now().Add(cdbm.RootFlags.ConnectTimeout)
+	}
+
+	return nil
+}
+
+// connectionSettings returns settings to connect to database with in the
+// order they should be tried, based on root flags and CDBM#DatabaseConfig
+func (cdbm *CDBM) connectionSettings() ([]webutil.DatabaseSetting, error) {
+	settings, err := cdbm.rootFlagsSettings()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Falls back to connections in config file if --database or --dsn flag is
+	// not set or if user wants to use them when that connection fails
+	if len(settings) == 0 || cdbm.RootFlags.UseFileOnFail {
+		settings = append(settings, cdbm.fallbackSettings()...)
+	}
+
+	return cdbm.resolveSettings(settings)
+}
+
+// rootFlagsSettings returns settings set by --dsn or --database root flags
+func (cdbm *CDBM) rootFlagsSettings() ([]DatabaseSetting, error) {
+	settings := make([]DatabaseSetting, 0)
+
+	if cdbm.RootFlags.DSN != "" {
+		if cdbm.RootFlags.Database != "" {
+			return nil, fmt.Errorf("--dsn can't be set along with --database")
 		}
 
-		if !foundConn {
-			return errors.WithStack(err)
+		setting, err := parseDSN(cdbm.RootFlags.DSN, cdbm.DBProtocolCfg)
+
+		if err != nil {
+			return nil, err
 		}
 
-		return nil
+		settings = append(settings, DatabaseSetting{
+			DatabaseSetting: setting,
+			PasswordFile:    cdbm.RootFlags.PasswordFile,
+			PasswordEnv:     cdbm.RootFlags.PasswordEnv,
+			PasswordCommand: cdbm.RootFlags.PasswordCommand,
+		})
 	}
 
 	// If user sets --database flag, then --user, --host, and --port flags are also required at minimum
-	if cfg.Database != "" {
-		if cfg.User == "" || cfg.Host == "" || cfg.Port == -1 {
+	if cdbm.RootFlags.Database != "" {
+		if cdbm.RootFlags.User == "" || cdbm.RootFlags.Host == "" || cdbm.RootFlags.Port <= 0 {
 			return nil, fmt.Errorf("--user, --host and --port must be set if --database is set")
 		}
 
-		cdbm.currentDBSettings = webutil.DatabaseSetting{
-			BaseAuthSetting: webutil.BaseAuthSetting{
-				Host:     cfg.Host,
-				User:     cfg.User,
-				Password: cfg.Password,
-				Port:     cfg.Port,
+		settings = append(settings, DatabaseSetting{
+			DatabaseSetting: webutil.DatabaseSetting{
+				BaseAuthSetting: webutil.BaseAuthSetting{
+					Host:     cdbm.RootFlags.Host,
+					User:     cdbm.RootFlags.User,
+					Password: cdbm.RootFlags.Password,
+					Port:     cdbm.RootFlags.Port,
+				},
+				DBName:      cdbm.RootFlags.Database,
+				SSLMode:     cdbm.RootFlags.SSLMode,
+				SSL:         cdbm.RootFlags.SSL,
+				SSLRootCert: cdbm.RootFlags.SSLRootCert,
+				SSLKey:      cdbm.RootFlags.SSLKey,
+				SSLCert:     cdbm.RootFlags.SSLCert,
 			},
-			DBName:      cfg.Database,
-			SSLMode:     cfg.SSLMode,
-			SSL:         cfg.SSL,
-			SSLRootCert: cfg.SSLRootCert,
-		}
+			PasswordFile:    cdbm.RootFlags.PasswordFile,
+			PasswordEnv:     cdbm.RootFlags.PasswordEnv,
+			PasswordCommand: cdbm.RootFlags.PasswordCommand,
+		})
+	}
 
-		if cdbm.DB, err = webutil.NewDB(cdbm.currentDBSettings, cdbm.DBProtocolCfg.DatabaseType); err != nil {
-			if cfg.UseFileOnFail {
-				if err = searchConn(); err != nil {
-					return nil, errors.WithStack(err)
-				}
-			} else {
-				return nil, errors.WithStack(err)
-			}
-		}
-	} else {
-		if err = searchConn(); err != nil {
-			return nil, errors.WithStack(err)
+	return settings, nil
+}
+
+// resolveSettings resolves password and ssl settings of given settings
+// and keeps track of passwords so they can be redacted
+func (cdbm *CDBM) resolveSettings(settings []DatabaseSetting) ([]webutil.DatabaseSetting, error) {
+	connSettings := make([]webutil.DatabaseSetting, 0, len(settings))
+
+	for _, setting := range settings {
+		connSetting, err := resolvePassword(setting)
+
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	if cdbm.DB == nil {
-		return nil, fmt.Errorf("no connection to database was established.  Check config file or cli settings for proper settings")
-	}
+		if connSetting, err = applySSL(connSetting, cdbm.logger()); err != nil {
+			return nil, err
+		}
 
-	if driverCfg != nil {
-		if _, err = cdbmutil.GetDatabaseDriver(
-			cdbm.DB.DB,
-			cdbm.DBProtocolCfg.DBProtocol,
-			driverCfg,
-		); err != nil {
-			return nil, errors.WithStack(err)
+		if connSetting.Password != "" {
+			cdbm.passwords = append(cdbm.passwords, connSetting.Password)
 		}
 
-		cdbm.DBProtocolCfg.DriverConfig = driverCfg
+		connSettings = append(connSettings, connSetting)
 	}
 
-	return cdbm, nil
+	return connSettings, nil
 }
 
 // NewTestCDBM will initiate a new *CDBM instance with the bare minimum fields required to run CDBM#Migrate
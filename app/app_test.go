@@ -1,7 +1,81 @@
 package app
 
-import "github.com/DATA-DOG/go-sqlmock"
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+)
 
 var sqlAnyMatcher = sqlmock.QueryMatcherFunc(func(expectedSQL, actualSQL string) error {
 	return nil
 })
+
+func TestNewCDBMFromConfig(t *testing.T) {
+	var err error
+
+	db, _, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that protocol is still required
+	if _, err = NewCDBMFromConfig(CDBM{}, nil); err == nil {
+		t.Errorf("should have error for missing db protocol")
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that given database connection is used
+	cdbm, err := NewCDBMFromConfig(CDBM{
+		DB: sqlx.NewDb(db, webutil.Postgres),
+		RootFlags: RootFlagsConfig{
+			DBProtocol: string(cdbmutil.PostgresProtocol),
+		},
+		MigrateFlags: MigrateFlagsConfig{
+			MigrationsDir: "/migrations",
+		},
+	}, nil)
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if cdbm.DBProtocolCfg.DBProtocol != cdbmutil.PostgresProtocol {
+		t.Errorf("should have postgres protocol config; got %s\n", cdbm.DBProtocolCfg.DBProtocol)
+	}
+
+	if cdbm.MigrateFlags.MigrationsDir != "/migrations" {
+		t.Errorf("should have kept migrate flags; got %+v\n", cdbm.MigrateFlags)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that unset target version migrates to latest version instead
+	// of down to version 0
+	if err = cdbm.applyTargetVersion([]migrationApplyConfig{{Version: 1}, {Version: 2}}); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if cdbm.migrateCfg.TargetVersion != 2 {
+		t.Errorf("should have target latest version 2; got %d\n", cdbm.migrateCfg.TargetVersion)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that --database requires other connection settings
+	if _, err = NewCDBMFromConfig(CDBM{
+		RootFlags: RootFlagsConfig{
+			DBProtocol: string(cdbmutil.PostgresProtocol),
+			Database:   "app",
+			Host:       "localhost",
+		},
+	}, nil); err == nil {
+		t.Errorf("should have error for missing user and port")
+	}
+}
@@ -0,0 +1,349 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/pkg/errors"
+)
+
+// applySchemaAppliedQueries sets up our insert and delete schema_migrations_applied
+// queries by applying sql bind var
+func (cdbm *CDBM) applySchemaAppliedQueries() error {
+	appliedInsert, _, err := webutil.InQueryRebind(
+		cdbm.DBProtocolCfg.SQLBindVar,
+		`
+		insert into schema_migrations_applied(version, is_custom_migration)
+		values(?, ?)
+		on conflict (version) do nothing;
+		`,
+		0,
+		true,
+	)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	appliedDelete, _, err := webutil.InQueryRebind(
+		cdbm.DBProtocolCfg.SQLBindVar,
+		`
+		delete from schema_migrations_applied where version > ?;
+		`,
+		0,
+	)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	cdbm.migrateCfg.AppliedInsertQuery = appliedInsert
+	cdbm.migrateCfg.AppliedDeleteQuery = appliedDelete
+	return nil
+}
+
+// queryAppliedMigrations returns every version recorded in schema_migrations_applied table
+//
+// Returned bool will be false if schema_migrations_applied table doesn't exist
+func (cdbm *CDBM) queryAppliedMigrations() (map[int]bool, bool, error) {
+	var err error
+
+	if err = cdbm.DBProtocolCfg.AppliedTableSearch(cdbm.DB); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+
+		return nil, false, errors.WithStack(err)
+	}
+
+	rows, err := cdbm.DB.Queryx(
+		`
+		select
+			schema_migrations_applied.version
+		from
+			schema_migrations_applied
+		`,
+	)
+
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+
+	for rows.Next() {
+		var version int
+
+		if err = rows.Scan(&version); err != nil {
+			return nil, false, errors.WithStack(err)
+		}
+
+		applied[version] = true
+	}
+
+	return applied, true, errors.WithStack(rows.Err())
+}
+
+// getAppliedMigrations queries and returns versions recorded in schema_migrations_applied table
+//
+// If schema_migrations_applied table doesn't exist, it creates it and records every version
+// below starting version, along with starting version if it isn't dirty, as those versions
+// were applied before we started keeping track of them
+func (cdbm *CDBM) getAppliedMigrations(cfgs []migrationApplyConfig) (map[int]bool, error) {
+	applied, found, err := cdbm.queryAppliedMigrations()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		return applied, nil
+	}
+
+	if _, err = cdbm.DB.Exec(
+		`
+		CREATE TABLE public.schema_migrations_applied (
+			version INT8 NOT NULL primary key,
+			is_custom_migration boolean not null default false,
+			applied_at timestamptz not null default now()
+		);
+		`,
+	); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	applied = make(map[int]bool)
+
+	if cdbm.migrateCfg.SchemaMigration.SchemaCfg.NoRows {
+		return applied, nil
+	}
+
+	for _, cfg := range cfgs {
+		if cfg.Version > cdbm.migrateCfg.SchemaMigration.StartingVersion {
+			break
+		}
+
+		if cfg.Version == cdbm.migrateCfg.SchemaMigration.StartingVersion &&
+			cdbm.migrateCfg.SchemaMigration.Dirty {
+			break
+		}
+
+		if _, err = cdbm.DB.Exec(
+			cdbm.migrateCfg.AppliedInsertQuery,
+			cfg.Version,
+			cfg.isCustomMigration(),
+		); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		applied[cfg.Version] = true
+	}
+
+	return applied, nil
+}
+
+// getUnappliedMigrations returns the configs below the starting version that have
+// no entry in schema_migrations_applied table and weren't skipped by tags
+//
+// These are usually migrations from a branch that was merged after a branch with
+// a higher version had already been applied
+func (cdbm *CDBM) getUnappliedMigrations(cfgs []migrationApplyConfig) []migrationApplyConfig {
+	unapplied := make([]migrationApplyConfig, 0)
+
+	if cdbm.migrateCfg.SchemaMigration.SchemaCfg.NoRows {
+		return unapplied
+	}
+
+	for _, cfg := range cfgs {
+		if cfg.Version >= cdbm.migrateCfg.SchemaMigration.StartingVersion {
+			break
+		}
+
+		if !cdbm.migrateCfg.AppliedVersions[cfg.Version] && !cdbm.migrateCfg.SkippedVersions[cfg.Version] {
+			unapplied = append(unapplied, cfg)
+		}
+	}
+
+	return unapplied
+}
+
+// recordApplied adds given config's version to schema_migrations_applied table
+func (cdbm *CDBM) recordApplied(cfg migrationApplyConfig) error {
+	if !cdbm.migrateCfg.TrackApplied {
+		return nil
+	}
+
+	if _, err := cdbm.DB.Exec(
+		cdbm.migrateCfg.AppliedInsertQuery,
+		cfg.Version,
+		cfg.isCustomMigration(),
+	); err != nil {
+		cdbm.migrationLogger(cfg.Version, cfg.kind(), cdbm.migrateCfg.MigrateType).Error("can't record applied version", "error", err)
+
+		return errors.WithStack(err)
+	}
+
+	cdbm.migrateCfg.AppliedVersions[cfg.Version] = true
+	return nil
+}
+
+// removeAppliedAbove removes every version greater than given version from
+// schema_migrations_applied and schema_migrations_skipped tables
+func (cdbm *CDBM) removeAppliedAbove(version int) error {
+	if !cdbm.migrateCfg.TrackApplied {
+		return nil
+	}
+
+	for _, query := range []string{
+		cdbm.migrateCfg.AppliedDeleteQuery,
+		cdbm.migrateCfg.SkippedDeleteAboveQuery,
+	} {
+		if _, err := cdbm.DB.Exec(query, version); err != nil {
+			cdbm.logger().Error("can't remove versions above version", "version", version, "error", err)
+
+			return errors.WithStack(err)
+		}
+	}
+
+	for k := range cdbm.migrateCfg.AppliedVersions {
+		if k > version {
+			delete(cdbm.migrateCfg.AppliedVersions, k)
+		}
+	}
+	for k := range cdbm.migrateCfg.SkippedVersions {
+		if k > version {
+			delete(cdbm.migrateCfg.SkippedVersions, k)
+		}
+	}
+
+	return nil
+}
+
+// applyOutOfOrderMigrations applies the up migration of each given config
+// without changing the version in schema_migrations table
+//
+// File migrations are read and executed directly as the migrate library
+// can only move between versions in order
+func (cdbm *CDBM) applyOutOfOrderMigrations(cfgs []migrationApplyConfig) error {
+	for _, cfg := range cfgs {
+		if err := cdbm.applyOutOfOrderMigration(cfg); err != nil {
+			return err
+		}
+
+		fmt.Printf("Applied out of order version %d\n", cfg.Version)
+	}
+
+	return nil
+}
+
+// applyOutOfOrderMigration applies up migration of given config out of order
+// within span of its version
+func (cdbm *CDBM) applyOutOfOrderMigration(cfg migrationApplyConfig) error {
+	start := time.Now()
+	end := cdbm.startVersionSpan(cfg.Version, cfg.kind())
+	err := cdbm.applyOutOfOrderVersion(cfg)
+	end(err)
+	cdbm.observeStep(cfg.kind(), cdbmutil.MigrateTypeUp, start, err)
+	return err
+}
+
+// applyOutOfOrderVersion applies up migration of given config out of order,
+// records it in schema_migrations_applied table and removes it from
+// schema_migrations_skipped table if it was skipped
+//
+// File migration is executed within a transaction along with updating its
+// records so failure leaves neither schema changes nor records behind and
+// version is found as unapplied or skipped again on next run.  Custom migration
+// is only rolled back on failure if CustomMigration#Transaction is set
+func (cdbm *CDBM) applyOutOfOrderVersion(cfg migrationApplyConfig) error {
+	var err error
+
+	log := cdbm.migrationLogger(cfg.Version, cfg.kind(), cdbmutil.MigrateTypeUp)
+
+	if cfg.isCustomMigration() {
+		if err = cdbm.runCustomMigration(cfg.CustomMigration, cfg.CustomMigration.Up, log); err != nil {
+			if cfg.CustomMigration.Transaction {
+				log.Error("out of order custom migration failed and was rolled back", "error", err)
+
+				return fmt.Errorf("failed on out of order custom migration for version: '%d' and rolled back.  Error: %+v", cfg.Version, err)
+			}
+
+			log.Error("out of order custom migration failed and may be partially applied", "error", err)
+
+			return fmt.Errorf(
+				"failed on out of order custom migration for version: '%d' which may be partially applied as it's not in a transaction.  Error: %+v",
+				cfg.Version,
+				err,
+			)
+		}
+
+		if err = cdbm.recordApplied(cfg); err != nil {
+			return err
+		}
+
+		if cdbm.migrateCfg.SkippedVersions[cfg.Version] {
+			return cdbm.removeSkipped(cfg.Version)
+		}
+
+		return nil
+	}
+
+	if cfg.UpFile == "" {
+		return fmt.Errorf("no up migration file found for out of order version: '%d'", cfg.Version)
+	}
+
+	fileBytes, err := ioutil.ReadFile(cfg.UpFile)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tx, err := cdbm.DB.BeginTxx(cdbm.migrateContext(), nil)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, err = tx.Exec(string(fileBytes)); err == nil && cdbm.migrateCfg.TrackApplied {
+		_, err = tx.Exec(cdbm.migrateCfg.AppliedInsertQuery, cfg.Version, false)
+	}
+	if err == nil && cdbm.migrateCfg.SkippedVersions[cfg.Version] {
+		_, err = tx.Exec(cdbm.migrateCfg.SkippedDeleteQuery, cfg.Version)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+
+	if err != nil {
+		log.Error("out of order file migration failed and was rolled back", "error", err)
+
+		return fmt.Errorf("failed on out of order file migration for version: '%d' and rolled back.  Error: %+v", cfg.Version, err)
+	}
+
+	if cdbm.migrateCfg.TrackApplied {
+		cdbm.migrateCfg.AppliedVersions[cfg.Version] = true
+	}
+
+	delete(cdbm.migrateCfg.SkippedVersions, cfg.Version)
+	return nil
+}
+
+// unappliedVersions is util function that returns the versions of given configs
+func unappliedVersions(cfgs []migrationApplyConfig) []int {
+	versions := make([]int, 0, len(cfgs))
+
+	for _, cfg := range cfgs {
+		versions = append(versions, cfg.Version)
+	}
+
+	return versions
+}
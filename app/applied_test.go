@@ -0,0 +1,250 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestGetAppliedMigrations(t *testing.T) {
+	var err error
+
+	db, mockDB, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	dbpCfg := cdbmutil.DefaultProtocolMap[cdbmutil.CockroachdbProtocol]
+	dbpCfg.AppliedTableSearch = func(db webutil.DBInterface) error {
+		return sql.ErrNoRows
+	}
+
+	cfgs := []migrationApplyConfig{
+		{
+			Version: 1,
+		},
+		{
+			Version: 2,
+		},
+		{
+			Version: 3,
+		},
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that versions up to starting version are recorded when
+	// schema_migrations_applied table is first created
+	c := &CDBM{
+		DB:            sqlx.NewDb(db, webutil.Postgres),
+		DBProtocolCfg: dbpCfg,
+		migrateCfg: migrateState{
+			SchemaMigration: schemaMigration{
+				StartingVersion: 2,
+			},
+		},
+	}
+
+	mockDB.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("").WithArgs(1, false).WillReturnResult(sqlmock.NewResult(1, 1))
+	mockDB.ExpectExec("").WithArgs(2, false).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	applied, err := c.getAppliedMigrations(cfgs)
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if len(applied) != 2 || !applied[1] || !applied[2] {
+		t.Errorf("should have versions 1 and 2 applied; got %v\n", applied)
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that a dirty starting version is not recorded
+	c.migrateCfg.SchemaMigration.Dirty = true
+
+	mockDB.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("").WithArgs(1, false).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if applied, err = c.getAppliedMigrations(cfgs); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if len(applied) != 1 || !applied[1] {
+		t.Errorf("should have version 1 applied; got %v\n", applied)
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+}
+
+func TestGetUnappliedMigrations(t *testing.T) {
+	cfgs := []migrationApplyConfig{
+		{
+			Version: 50,
+		},
+		{
+			Version: 51,
+		},
+		{
+			Version: 52,
+		},
+		{
+			Version: 53,
+		},
+	}
+
+	c := &CDBM{
+		migrateCfg: migrateState{
+			AppliedVersions: map[int]bool{
+				50: true,
+				52: true,
+			},
+			SchemaMigration: schemaMigration{
+				StartingVersion: 52,
+			},
+		},
+	}
+
+	unapplied := unappliedVersions(c.getUnappliedMigrations(cfgs))
+
+	if len(unapplied) != 1 || unapplied[0] != 51 {
+		t.Errorf("should have version 51 unapplied; got %v\n", unapplied)
+	}
+
+	// --------------------------------------------------------------------------
+
+	c.migrateCfg.SchemaMigration.SchemaCfg.NoRows = true
+
+	if unapplied = unappliedVersions(c.getUnappliedMigrations(cfgs)); len(unapplied) != 0 {
+		t.Errorf("should not have unapplied versions; got %v\n", unapplied)
+	}
+}
+
+func TestApplyOutOfOrderMigrations(t *testing.T) {
+	var err error
+
+	dir, err := ioutil.TempDir("", "cdbm-out-of-order")
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	upFile := filepath.Join(dir, "000002_update.up.sql")
+
+	if err = ioutil.WriteFile(upFile, []byte("create table foo(id int);"), os.ModePerm); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	db, mockDB, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	c := &CDBM{
+		DB: sqlx.NewDb(db, webutil.Postgres),
+		migrateCfg: migrateState{
+			TrackApplied:    true,
+			AppliedVersions: make(map[int]bool),
+		},
+	}
+
+	cfg := migrationApplyConfig{
+		Version: 2,
+		UpFile:  upFile,
+	}
+
+	// Validating that file migration and its record are committed together
+	mockDB.ExpectBegin()
+	mockDB.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("").WithArgs(2, false).WillReturnResult(sqlmock.NewResult(1, 1))
+	mockDB.ExpectCommit()
+
+	if err = c.applyOutOfOrderMigrations([]migrationApplyConfig{cfg}); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if !c.migrateCfg.AppliedVersions[2] {
+		t.Errorf("should have version 2 applied")
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that failed file migration is rolled back and not recorded
+	c.migrateCfg.AppliedVersions = make(map[int]bool)
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectExec("").WillReturnError(errors.New("syntax error"))
+	mockDB.ExpectRollback()
+
+	if err = c.applyOutOfOrderMigrations([]migrationApplyConfig{cfg}); err == nil {
+		t.Errorf("should have error")
+	}
+
+	if c.migrateCfg.AppliedVersions[2] {
+		t.Errorf("should not have version 2 applied")
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that failed custom migration in transaction is rolled back
+	// and not recorded
+	customCfg := migrationApplyConfig{
+		Version: 3,
+		CustomMigration: cdbmutil.CustomMigration{
+			Transaction: true,
+			Up: func(db webutil.DBInterface) error {
+				if _, err := db.Exec("insert into foo values(1);"); err != nil {
+					return err
+				}
+
+				return errors.New("custom up migration error")
+			},
+			Down: func(db webutil.DBInterface) error {
+				return nil
+			},
+		},
+	}
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectExec("").WillReturnResult(sqlmock.NewResult(1, 1))
+	mockDB.ExpectRollback()
+
+	if err = c.applyOutOfOrderMigrations([]migrationApplyConfig{customCfg}); err == nil {
+		t.Errorf("should have error")
+	}
+
+	if c.migrateCfg.AppliedVersions[3] {
+		t.Errorf("should not have version 3 applied")
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+}
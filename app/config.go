@@ -0,0 +1,630 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	// EnvPrefix is prefix of every enviroment variable bound to config fields
+	// ie. CDBM_MIGRATE_FLAGS_MIGRATIONS_DIR
+	EnvPrefix = "CDBM"
+)
+
+var (
+	// envVarRegex matches "${VAR}" and "${VAR:-default}" within config files
+	envVarRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+	// dsnPasswordRegex matches password of key/value form of dsn
+	dsnPasswordRegex = regexp.MustCompile(`password\s*=\s*('(\\.|[^'])*'|\S+)`)
+)
+
+// configFilePaths splits given value of config enviroment variable into
+// config file paths using os path list separator ie. "base.yml:prod.yml"
+func configFilePaths(envValue string) []string {
+	paths := make([]string, 0)
+
+	for _, path := range filepath.SplitList(envValue) {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// interpolateEnv replaces every "${VAR}" in given content with value of
+// enviroment variable VAR, or with default if "${VAR:-default}" is used and
+// VAR is not set or empty
+//
+// Lines that are only comments are left as is and error is returned with
+// every variable that is not set and has no default
+func interpolateEnv(content []byte) ([]byte, error) {
+	missing := make([]string, 0)
+	lines := bytes.Split(content, []byte("\n"))
+
+	for i, line := range lines {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("#")) {
+			continue
+		}
+
+		lines[i] = envVarRegex.ReplaceAllFunc(line, func(match []byte) []byte {
+			groups := envVarRegex.FindSubmatch(match)
+			value, ok := os.LookupEnv(string(groups[1]))
+
+			if groups[2] != nil && value == "" {
+				return groups[3]
+			}
+
+			if !ok {
+				missing = append(missing, string(groups[1]))
+			}
+
+			return []byte(value)
+		})
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("enviroment variables %v used in config are not set", missing)
+	}
+
+	return bytes.Join(lines, []byte("\n")), nil
+}
+
+// readConfigFiles reads every config file of given paths into given viper
+// instance with each file merged over the ones before it so files can be
+// layered ie. base config, per enviroment config then local overrides
+func readConfigFiles(v *viper.Viper, paths []string) error {
+	for i, path := range paths {
+		content, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if content, err = interpolateEnv(content); err != nil {
+			return errors.Wrapf(err, "invalid config file '%s'", path)
+		}
+
+		configType := strings.TrimPrefix(filepath.Ext(path), ".")
+
+		if configType == "" {
+			configType = "yaml"
+		}
+
+		v.SetConfigType(configType)
+
+		if i == 0 {
+			err = v.ReadConfig(bytes.NewReader(content))
+		} else {
+			err = v.MergeConfig(bytes.NewReader(content))
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "invalid config file '%s'", path)
+		}
+	}
+
+	return nil
+}
+
+// bindEnvs binds an enviroment variable to every field of given flags config
+// struct so it can be set without config file
+//
+// Enviroment variable names are EnvPrefix, section and field mapstructure tag
+// upper cased and joined by "_" ie. CDBM_ROOT_FLAGS_HOST
+//
+// Fields that are structs are bound by their own fields ie. CDBM_ROOT_FLAGS_SESSION_MAX_OPEN_CONNS
+// and fields that are maps are skipped
+func bindEnvs(v *viper.Viper, section string, flagsCfg interface{}) error {
+	cfgType := reflect.TypeOf(flagsCfg)
+
+	for i := 0; i < cfgType.NumField(); i++ {
+		field := cfgType.Field(i)
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+
+		if tag == "" || tag == "-" || field.Type.Kind() == reflect.Map {
+			continue
+		}
+
+		key := section + "." + tag
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := bindEnvs(v, key, reflect.Zero(field.Type).Interface()); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := v.BindEnv(key, envName(section, tag)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// bindFlagsEnvs binds enviroment variables to every field of root, migrate,
+// log, drop and diff flags config
+func bindFlagsEnvs(v *viper.Viper) error {
+	sections := []struct {
+		name     string
+		flagsCfg interface{}
+	}{
+		{
+			name:     "root_flags",
+			flagsCfg: RootFlagsConfig{},
+		},
+		{
+			name:     "migrate_flags",
+			flagsCfg: MigrateFlagsConfig{},
+		},
+		{
+			name:     "log_flags",
+			flagsCfg: LogFlagsConfig{},
+		},
+		{
+			name:     "drop_flags",
+			flagsCfg: DropFlagsConfig{},
+		},
+		{
+			name:     "diff_flags",
+			flagsCfg: DiffFlagsConfig{},
+		},
+	}
+
+	for _, section := range sections {
+		if err := bindEnvs(v, section.name, section.flagsCfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// configTemplate is starter config written by ConfigInit
+const configTemplate = `# cdbm config
+#
+# Point CDBM_CONFIG enviroment variable to this file.  Multiple files can be
+# layered ie. CDBM_CONFIG=base.yml:prod.yml and "${VAR}" or "${VAR:-default}"
+# are replaced by enviroment variables
+#
+# Every flags setting can also be set by enviroment variable named
+# CDBM_<SECTION>_<SETTING> ie. CDBM_MIGRATE_FLAGS_MIGRATIONS_DIR
+
+root_flags:
+  # Type of database.  Available values: postgres | cockroachdb
+  db_protocol: postgres
+  # Connection used instead of database_config when set.  Either set
+  # database_url ie. postgres://user@localhost:5432/app?sslmode=disable
+  # or database, host, port and user
+  database_url: ""
+  database: ""
+  host: ""
+  port: 0
+  user: ""
+  # Only one of password, password_file, password_env or password_command
+  password: ""
+  password_file: ""
+  password_env: ""
+  password_command: ""
+  # Available values: disable | allow | prefer | require | verify-ca | verify-full
+  ssl_mode: ""
+  ssl: false
+  ssl_root_cert: ""
+  ssl_cert: ""
+  ssl_key: ""
+  # Use database_config connections if connection above fails
+  use_file_on_fail: false
+  # Name of database_config entry to use
+  target: ""
+  # Retries after every connection fails with backoff doubling up to 30s
+  # and total time budget ie. 5s, 1m
+  connect_retries: 0
+  connect_backoff: 1s
+  connect_timeout: 0s
+  # Connection pool and session parameters set on every connection
+  session:
+    max_open_conns: 0
+    max_idle_conns: 0
+    conn_max_lifetime: 0s
+    params:
+      application_name: cdbm
+  # Min level of log entries: debug | info | warn | error
+  log_level: info
+  # Format of log entries: text | json
+  log_format: text
+  # Set from CDBM_CONFIG and doesn't need to be set
+  env_var: ""
+
+migrate_flags:
+  # Available values: file:// | go-bindata | github:// | github-ee:// |
+  # bitbucket:// | gitlab:// | s3:// | gcs://
+  migrations_protocol: file://
+  migrations_dir: ./migrations
+  # Version to migrate to.  -1 migrates to latest version
+  target_version: -1
+  # Versions to migrate up (positive) or down (negative) instead of target_version
+  steps: 0
+  rollback_on_failure: false
+  reset_dirty_flag: false
+  allow_out_of_order: false
+  # Skip migrations with any of these tags or only apply skipped ones that have them
+  skip_tags: []
+  only_tags: []
+  # Log and display every statement of custom migrations with its duration
+  # File migrations are only logged per file
+  verbose: false
+  # Write prometheus metrics of migration to this file for node_exporter textfile collector
+  metrics_file: ""
+  # Write schema snapshot to this file after migrating, as json if it ends with .json, else as sql
+  snapshot_file: ""
+
+log_flags:
+  log_file: ""
+  # Size in megabytes log file can reach before it is rotated, 0 to disable
+  log_max_size: 0
+  # Age of first entry of log file before it is rotated ie. 168h, 0 to disable
+  log_max_age: 0s
+  # Number of rotated log files to keep, 0 to keep all
+  log_max_backups: 0
+
+drop_flags:
+  confirm: false
+
+diff_flags:
+  # cdbmutil config used to create scratch database for diff, squash and
+  # verify-reversible, defaults to CDBM_UTIL_CONFIG
+  util_config: ""
+
+# Named connections tried in order.  Without root_flags.target, every entry
+# is tried sorted by name
+database_config:
+  dev:
+    - host: localhost
+      port: 5432
+      user: postgres
+      db_name: app
+      ssl_mode: disable
+      password_env: CDBM_DEV_PASSWORD
+
+# Settings of migrate_flags, log_flags, drop_flags and diff_flags overridden when
+# database_config entry of same name is used as root_flags.target
+targets:
+  dev:
+    migrate_flags:
+      rollback_on_failure: true
+`
+
+// configSection is section of config file with its flags config
+type configSection struct {
+	name  string
+	value reflect.Value
+}
+
+// ConfigInit writes starter config file with every setting to given path
+//
+// If path is empty, config is written to stdout and if file already exists,
+// force must be set to overwrite it
+func ConfigInit(path string, force bool) error {
+	if path == "" {
+		fmt.Print(configTemplate)
+		return nil
+	}
+
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("config file '%s' already exists.  Set --force to overwrite it", path)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(configTemplate), 0600); err != nil {
+		return errors.WithStack(err)
+	}
+
+	fmt.Printf("Wrote config file %s\n", path)
+	return nil
+}
+
+// ConfigShow prints the effective config built from given root flags, enviroment
+// variables and config files along with source of each setting
+//
+// Passwords are masked
+func ConfigShow(cfg RootFlagsConfig) error {
+	cdbm, err := loadCDBM(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	if err = cdbm.applyTarget(); err != nil {
+		return err
+	}
+
+	paths := configFilePaths(cdbm.RootFlags.EnvVar)
+	fileV := viper.New()
+
+	if err = readConfigFiles(fileV, paths); err != nil {
+		return err
+	}
+
+	fmt.Printf("config files: %v\n", paths)
+
+	sections := []configSection{
+		{
+			name:  "root_flags",
+			value: reflect.ValueOf(cdbm.RootFlags),
+		},
+		{
+			name:  "migrate_flags",
+			value: reflect.ValueOf(cdbm.MigrateFlags),
+		},
+		{
+			name:  "log_flags",
+			value: reflect.ValueOf(cdbm.LogFlags),
+		},
+		{
+			name:  "drop_flags",
+			value: reflect.ValueOf(cdbm.DropFlags),
+		},
+		{
+			name:  "diff_flags",
+			value: reflect.ValueOf(cdbm.DiffFlags),
+		},
+	}
+
+	targetCfg := cdbm.Targets[cdbm.RootFlags.Target]
+	targetOverrides := map[string]map[string]interface{}{
+		"migrate_flags": targetCfg.MigrateFlags,
+		"log_flags":     targetCfg.LogFlags,
+		"drop_flags":    targetCfg.DropFlags,
+		"diff_flags":    targetCfg.DiffFlags,
+	}
+
+	// showSection prints every field of given section value where flagsValue
+	// is value set by flags for section if any
+	var showSection func(name string, value, flagsValue reflect.Value)
+
+	showSection = func(name string, value, flagsValue reflect.Value) {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+
+			if tag == "" || tag == "-" || tag == "env_var" {
+				continue
+			}
+
+			key := name + "." + tag
+
+			var fieldFlagsValue reflect.Value
+
+			if flagsValue.IsValid() {
+				fieldFlagsValue = flagsValue.Field(i)
+			}
+
+			if field.Type.Kind() == reflect.Struct {
+				showSection(key, value.Field(i), fieldFlagsValue)
+				continue
+			}
+
+			section := strings.Split(name, ".")[0]
+			source := "default"
+
+			_, isTargetOverride := targetOverrides[section][tag]
+
+			switch {
+			case fieldFlagsValue.IsValid() && isFlagSet(fieldFlagsValue):
+				source = "flag"
+			case isTargetOverride && name == section:
+				source = "target " + cdbm.RootFlags.Target
+			case key == "root_flags.database_url" && cdbm.dsnFromEnv:
+				source = "env " + DATABASE_URL
+			case field.Type.Kind() != reflect.Map && isEnvSet(envName(name, tag)):
+				source = "env " + envName(name, tag)
+			case fileV.IsSet(key):
+				source = "file"
+			}
+
+			fmt.Printf(
+				"%s: %s (%s)\n",
+				key,
+				maskConfigValue(tag, configValueString(value.Field(i))),
+				source,
+			)
+		}
+	}
+
+	for _, section := range sections {
+		var flagsValue reflect.Value
+
+		if section.name == "root_flags" {
+			flagsValue = reflect.ValueOf(cfg)
+		}
+
+		showSection(section.name, section.value, flagsValue)
+	}
+
+	for _, name := range cdbm.databaseConfigNames() {
+		for i, setting := range cdbm.DatabaseConfig[name] {
+			fmt.Printf("database_config.%s[%d]: %s (file)\n", name, i, describeSetting(setting))
+		}
+	}
+
+	return nil
+}
+
+// ConfigValidate verifies config built from given root flags, enviroment
+// variables and config files and pings every connection of root flags and
+// CDBM#DatabaseConfig, printing result of each
+//
+// Returns error if any problem was found
+func ConfigValidate(cfg RootFlagsConfig) error {
+	cdbm, err := loadCDBM(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+
+	check := func(name string, err error) {
+		if err != nil {
+			problems++
+			fmt.Printf("FAIL %s: %v\n", name, cdbm.redactErr(err))
+		} else {
+			fmt.Printf("ok   %s\n", name)
+		}
+	}
+
+	if err = cdbm.prepare(); err != nil {
+		check("root_flags", err)
+		return fmt.Errorf("config has %d problem(s)", problems)
+	}
+
+	check("root_flags", nil)
+
+	if cdbm.MigrateFlags.MigrationsProtocol != "" {
+		err = cdbm.checkMigrationsProtocol()
+
+		if err == nil && cdbm.MigrateFlags.MigrationsProtocol == cdbmutil.FileProtocol {
+			if _, err = os.Stat(cdbm.MigrateFlags.MigrationsDir); err != nil {
+				err = fmt.Errorf("can't read migrations dir: %v", err)
+			}
+		}
+
+		check("migrate_flags", err)
+	}
+
+	namedSettings, err := cdbm.namedSettings()
+
+	if err != nil {
+		check("root_flags connection", err)
+	}
+
+	for _, ns := range namedSettings {
+		_, _, err = cdbm.ping(ns.setting)
+		check(ns.name+" "+describeSetting(ns.setting), err)
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("config has %d problem(s)", problems)
+	}
+
+	return nil
+}
+
+// envName returns enviroment variable name bound to given section and field tag
+func envName(section, tag string) string {
+	return strings.ToUpper(strings.Replace(EnvPrefix+"_"+section+"_"+tag, ".", "_", -1))
+}
+
+// isEnvSet determines whether given enviroment variable is set
+func isEnvSet(name string) bool {
+	_, ok := os.LookupEnv(name)
+	return ok
+}
+
+// isFlagSet determines whether given root flag value was set by user which is
+// the same check NewCDBM uses to override config settings
+func isFlagSet(value reflect.Value) bool {
+	return !value.IsZero() && !(value.Kind() == reflect.Int && value.Int() == -1)
+}
+
+// configValueString returns string form of config value
+func configValueString(value reflect.Value) string {
+	if value.Kind() == reflect.Slice {
+		values := make([]string, 0, value.Len())
+
+		for i := 0; i < value.Len(); i++ {
+			values = append(values, fmt.Sprint(value.Index(i).Interface()))
+		}
+
+		return "[" + strings.Join(values, ",") + "]"
+	}
+
+	if value.Kind() == reflect.Map {
+		values := make([]string, 0, value.Len())
+
+		for _, k := range value.MapKeys() {
+			values = append(values, fmt.Sprintf("%v=%v", k.Interface(), value.MapIndex(k).Interface()))
+		}
+
+		sort.Strings(values)
+		return "[" + strings.Join(values, ",") + "]"
+	}
+
+	return fmt.Sprint(value.Interface())
+}
+
+// maskConfigValue masks password of given value based on its field tag
+func maskConfigValue(tag, value string) string {
+	switch tag {
+	case "password":
+		if value != "" {
+			return redactedPassword
+		}
+	case "database_url":
+		return maskDSN(value)
+	}
+
+	return value
+}
+
+// maskDSN masks password within url or key/value form of dsn
+func maskDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+
+		if err != nil {
+			return redactedPassword
+		}
+
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redactedPassword)
+		}
+
+		return u.String()
+	}
+
+	return dsnPasswordRegex.ReplaceAllString(dsn, "password="+redactedPassword)
+}
+
+// describeSetting returns summary of given database setting with password masked
+func describeSetting(setting DatabaseSetting) string {
+	desc := fmt.Sprintf(
+		"host=%s port=%d user=%s dbname=%s",
+		setting.Host,
+		setting.Port,
+		setting.User,
+		setting.DBName,
+	)
+
+	if setting.SSLMode != "" {
+		desc += " sslmode=" + setting.SSLMode
+	}
+	if setting.Password != "" {
+		desc += " password=" + redactedPassword
+	}
+	if setting.PasswordFile != "" {
+		desc += " password_file=" + setting.PasswordFile
+	}
+	if setting.PasswordEnv != "" {
+		desc += " password_env=" + setting.PasswordEnv
+	}
+	if setting.PasswordCommand != "" {
+		desc += " password_command=" + setting.PasswordCommand
+	}
+
+	return desc
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultConnectBackoff is backoff used between connection attempts
+	// if RootFlagsConfig#ConnectBackoff is not set
+	DefaultConnectBackoff = time.Second
+
+	// maxConnectBackoff is the max backoff between connection attempts
+	maxConnectBackoff = time.Second * 30
+)
+
+var (
+	// newDB is used to open database connections and is a var so tests can replace it
+	newDB = openDB
+
+	// sleep is used between connection attempts and is a var so tests can replace it
+	sleep = time.Sleep
+)
+
+// connectRetry keeps track of attempts to connect to database and backoff
+// between them based on RootFlagsConfig connect settings
+type connectRetry struct {
+	retries  int
+	backoff  time.Duration
+	deadline time.Time
+	attempt  int
+}
+
+// newConnectRetry returns *connectRetry based on RootFlagsConfig#ConnectRetries,
+// RootFlagsConfig#ConnectBackoff and CDBM#connectDeadline
+func (cdbm *CDBM) newConnectRetry() *connectRetry {
+	backoff := cdbm.RootFlags.ConnectBackoff
+
+	if backoff <= 0 {
+		backoff = DefaultConnectBackoff
+	}
+
+	return &connectRetry{
+		retries:  cdbm.RootFlags.ConnectRetries,
+		backoff:  backoff,
+		deadline: cdbm.connectDeadline,
+		attempt:  1,
+	}
+}
+
+// next will sleep for current backoff and return true if another attempt
+// should be made, else false if retries or time budget ran out
+//
+// Backoff is doubled after every attempt up to maxConnectBackoff and is cut
+// short if it would go past deadline
+func (c *connectRetry) next() bool {
+	if c.retries == 0 && c.deadline.IsZero() {
+		return false
+	}
+
+	if c.retries > 0 && c.attempt > c.retries {
+		return false
+	}
+
+	wait := c.backoff
+
+	if !c.deadline.IsZero() {
+		remaining := time.Until(c.deadline)
+
+		if remaining <= 0 {
+			return false
+		}
+
+		if wait > remaining {
+			wait = remaining
+		}
+	}
+
+	sleep(wait)
+
+	if c.backoff *= 2; c.backoff > maxConnectBackoff {
+		c.backoff = maxConnectBackoff
+	}
+
+	c.attempt++
+	return true
+}
+
+// connect tries each of given settings in order until a connection is
+// established, retrying all of them with backoff based on RootFlagsConfig
+// connect settings
+//
+// Every failed attempt is logged along with host that was tried and so is
+// the setting that was connected to
+func (cdbm *CDBM) connect(settings []webutil.DatabaseSetting) error {
+	var err error
+
+	if len(settings) == 0 {
+		return nil
+	}
+
+	retry := cdbm.newConnectRetry()
+
+	for {
+		for _, setting := range settings {
+			if cdbm.DB, err = newDB(setting, cdbm.DBProtocolCfg.DatabaseType, cdbm.RootFlags.Session); err == nil {
+				cdbm.currentDBSettings = setting
+				cdbm.logger().Info(
+					"connected to database",
+					"host", setting.Host,
+					"port", setting.Port,
+					"dbname", setting.DBName,
+					"user", setting.User,
+				)
+				return nil
+			}
+
+			cdbm.logger().Warn(
+				"connection attempt failed",
+				"attempt", retry.attempt,
+				"host", setting.Host,
+				"port", setting.Port,
+				"error", cdbm.redactErr(err),
+			)
+		}
+
+		if !retry.next() {
+			return errors.WithStack(cdbm.redactErr(err))
+		}
+	}
+}
+
+// Wait blocks until a simple query succeeds against current database connection
+//
+// Query is retried with the same retries, backoff and time budget used to connect
+func (cdbm *CDBM) Wait() error {
+	var err error
+
+	retry := cdbm.newConnectRetry()
+
+	for {
+		if _, err = cdbm.DB.Exec("select 1;"); err == nil {
+			fmt.Printf(
+				"Database at %s:%d is ready\n",
+				cdbm.currentDBSettings.Host,
+				cdbm.currentDBSettings.Port,
+			)
+			return nil
+		}
+
+		cdbm.logger().Warn(
+			"query attempt failed",
+			"attempt", retry.attempt,
+			"host", cdbm.currentDBSettings.Host,
+			"port", cdbm.currentDBSettings.Port,
+			"error", cdbm.redactErr(err),
+		)
+
+		if !retry.next() {
+			return errors.WithStack(cdbm.redactErr(err))
+		}
+	}
+}
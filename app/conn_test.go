@@ -0,0 +1,126 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestConnect(t *testing.T) {
+	var err error
+
+	db, _, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer func() {
+		newDB = openDB
+		sleep = time.Sleep
+	}()
+
+	settings := []webutil.DatabaseSetting{
+		{
+			BaseAuthSetting: webutil.BaseAuthSetting{
+				Host: "db-1",
+			},
+		},
+		{
+			BaseAuthSetting: webutil.BaseAuthSetting{
+				Host: "db-2",
+			},
+		},
+	}
+
+	tried := make([]string, 0)
+	waits := make([]time.Duration, 0)
+
+	// succeedOn returns newDB func that fails until given attempt number
+	succeedOn := func(attempt int) func(webutil.DatabaseSetting, string, SessionConfig) (*sqlx.DB, error) {
+		return func(setting webutil.DatabaseSetting, dbType string, session SessionConfig) (*sqlx.DB, error) {
+			tried = append(tried, setting.Host)
+
+			if len(tried) == attempt {
+				return sqlx.NewDb(db, webutil.Postgres), nil
+			}
+
+			return nil, errors.New("connection refused")
+		}
+	}
+
+	sleep = func(d time.Duration) {
+		waits = append(waits, d)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that without retries every setting is only tried once
+	newDB = succeedOn(-1)
+	c := &CDBM{}
+
+	if err = c.connect(settings); err == nil {
+		t.Fatalf("should have error")
+	}
+
+	if len(tried) != 2 || len(waits) != 0 {
+		t.Errorf("should have tried 2 settings without waiting; got %v and %v\n", tried, waits)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that retries back off exponentially and stop when connected
+	tried = tried[:0]
+	waits = waits[:0]
+	newDB = succeedOn(6)
+	c.RootFlags.ConnectRetries = 5
+	c.RootFlags.ConnectBackoff = time.Second * 20
+
+	if err = c.connect(settings); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if c.currentDBSettings.Host != "db-2" {
+		t.Errorf("should be connected to db-2; got %s\n", c.currentDBSettings.Host)
+	}
+
+	if len(waits) != 2 || waits[0] != time.Second*20 || waits[1] != maxConnectBackoff {
+		t.Errorf("should have waited 20s then 30s; got %v\n", waits)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that retries stop after max retries
+	tried = tried[:0]
+	waits = waits[:0]
+	newDB = succeedOn(-1)
+	c.RootFlags.ConnectRetries = 2
+
+	if err = c.connect(settings); err == nil {
+		t.Fatalf("should have error")
+	}
+
+	if len(tried) != 6 || len(waits) != 2 {
+		t.Errorf("should have tried 6 times and waited twice; got %v and %v\n", tried, waits)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that retries stop once time budget has run out
+	tried = tried[:0]
+	waits = waits[:0]
+	c.RootFlags.ConnectRetries = 0
+	c.connectDeadline = time.Now().Add(-time.Second)
+
+	if err = c.connect(settings); err == nil {
+		t.Fatalf("should have error")
+	}
+
+	if len(tried) != 2 || len(waits) != 0 {
+		t.Errorf("should have tried 2 settings without waiting; got %v and %v\n", tried, waits)
+	}
+}
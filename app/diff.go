@@ -0,0 +1,414 @@
+package app
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/pkg/errors"
+)
+
+// DiffFlagsConfig is config settings used for CDBM#Diff function
+type DiffFlagsConfig struct {
+	// UtilConfig is path to cdbmutil config file used to create and drop
+	// scratch database migrations are applied to by diff, squash and
+	// verify-reversible commands
+	//
+	// If empty, file CDBM_UTIL_CONFIG enviroment variable points to is used
+	UtilConfig string `yaml:"util_config" mapstructure:"util_config"`
+}
+
+// snapshotEntry is named entry of Snapshot compared by diffSnapshots where
+// value is everything about entry that must match
+type snapshotEntry struct {
+	name  string
+	value string
+}
+
+// Diff detects drift between schema of database and schema built by its migrations
+//
+// Scratch database is created with cdbmutil.GetNewDatabase, migrated to
+// current version of database and dropped once done.  Schema snapshots of
+// both are compared and every difference is displayed, where "missing" is
+// in migrations but not in database and "unexpected" is in database but
+// not in migrations ie. change applied by hand
+//
+// Versions up to current version that were skipped by tags or never applied
+// out of order are skipped in scratch database as well so they don't show
+// up as differences
+//
+// Returns error if any difference is found
+func (cdbm *CDBM) Diff(
+	getMigFunc cdbmutil.GetMigrationFunc,
+	fMigFunc cdbmutil.FileMigrationFunc,
+	cMigrations map[int]cdbmutil.CustomMigration,
+) error {
+	var err error
+
+	if err = cdbm.checkMigrationsProtocol(); err != nil {
+		return err
+	}
+
+	sm, err := cdbm.getSchemaMigration()
+
+	if err != nil {
+		return err
+	}
+
+	if sm.Dirty {
+		return fmt.Errorf("schema_migrations is dirty at version %d.  Resolve it before checking for drift", sm.StartingVersion)
+	}
+
+	cdbm.migrateCfg.CustomMigrations = cMigrations
+	cfgs, err := cdbm.verifyFilesAndMigrations()
+
+	if err != nil {
+		return err
+	}
+
+	applied, appliedFound, err := cdbm.queryAppliedMigrations()
+
+	if err != nil {
+		return err
+	}
+
+	skipped, _, err := cdbm.querySkippedMigrations()
+
+	if err != nil {
+		return err
+	}
+
+	// Versions skipped by tags or never applied out of order are left out of
+	// scratch database so it's built from same versions as database
+	notApplied := notAppliedVersions(cfgs, sm.StartingVersion, applied, appliedFound, skipped)
+
+	scratch, _, dropScratch, err := cdbm.newScratch()
+
+	if err != nil {
+		return err
+	}
+
+	defer dropScratch()
+
+	scratch.MigrateFlags.SkipTags = nil
+	scratch.skipVersions = notApplied
+
+	if len(notApplied) > 0 {
+		fmt.Printf("Versions %v are not applied to database and are left out of comparison\n", sortedVersions(notApplied))
+	}
+
+	// If no migration has been applied to database, scratch database is
+	// compared as is
+	if !sm.SchemaCfg.NoRows {
+		if err = scratch.migrateScratch(sm.StartingVersion, getMigFunc, fMigFunc, cMigrations); err != nil {
+			return err
+		}
+	}
+
+	expected, err := scratch.Snapshot()
+
+	if err != nil {
+		return err
+	}
+
+	actual, err := cdbm.Snapshot()
+
+	if err != nil {
+		return err
+	}
+
+	diffs := diffSnapshots(expected, actual)
+
+	cdbm.logger().Info(
+		"compared schema with migrations",
+		"host", cdbm.currentDBSettings.Host,
+		"version", sm.StartingVersion,
+		"differences", len(diffs),
+	)
+
+	if len(diffs) == 0 {
+		fmt.Printf("No drift\n")
+		return nil
+	}
+
+	fmt.Printf("Drift between database and migrations at version %d:\n", sm.StartingVersion)
+
+	for _, diff := range diffs {
+		fmt.Printf("  %s\n", diff)
+	}
+
+	return fmt.Errorf("found %d difference(s) between database and migrations", len(diffs))
+}
+
+// notAppliedVersions returns versions of given configs up to given version that
+// are recorded in given skipped versions or, if schema_migrations_applied table
+// was found, have no entry in given applied versions
+func notAppliedVersions(
+	cfgs []migrationApplyConfig,
+	version int,
+	applied map[int]bool,
+	appliedFound bool,
+	skipped map[int]bool,
+) map[int]bool {
+	notApplied := make(map[int]bool)
+
+	for _, cfg := range cfgs {
+		if cfg.Version > version {
+			break
+		}
+
+		if skipped[cfg.Version] || (appliedFound && !applied[cfg.Version]) {
+			notApplied[cfg.Version] = true
+		}
+	}
+
+	return notApplied
+}
+
+// sortedVersions returns given versions in ascending order
+func sortedVersions(versions map[int]bool) []int {
+	sorted := make([]int, 0, len(versions))
+
+	for version := range versions {
+		sorted = append(sorted, version)
+	}
+
+	sort.Ints(sorted)
+	return sorted
+}
+
+// newScratch creates scratch database with cdbmutil config of
+// DiffFlagsConfig#UtilConfig, or CDBM_UTIL_CONFIG if not set, and returns CDBM
+// connected to it with settings of cdbm along with cdbmutil settings used and
+// function that closes and drops it
+func (cdbm *CDBM) newScratch() (*CDBM, cdbmutil.CDBMUtilSettings, func(), error) {
+	var err error
+	var settings cdbmutil.CDBMUtilSettings
+
+	if cdbm.DiffFlags.UtilConfig != "" {
+		settings, err = cdbmutil.ReadCDBMUtilSettings(cdbm.DiffFlags.UtilConfig)
+	} else {
+		settings, err = cdbmutil.GetCDBMUtilSettings("")
+	}
+
+	if err != nil {
+		return nil, settings, nil, errors.WithStack(err)
+	}
+
+	db, dbName, err := cdbmutil.GetNewDatabase(
+		settings,
+		cdbmutil.DefaultExecCmd,
+		cdbmutil.DefaultGetDB,
+	)
+
+	if err != nil {
+		return nil, settings, nil, errors.WithStack(err)
+	}
+
+	cdbm.logger().Debug("created scratch database", "dbname", dbName)
+
+	scratch := &CDBM{
+		DB:                db,
+		DBProtocolCfg:     cdbm.DBProtocolCfg,
+		MigrateFlags:      cdbm.MigrateFlags,
+		RootFlags:         cdbm.RootFlags,
+		Logger:            cdbm.logger().With("dbname", dbName),
+		currentDBSettings: settings.BaseDatabaseSettings.Settings,
+		passwords:         cdbm.passwords,
+	}
+
+	scratch.currentDBSettings.DBName = dbName
+
+	drop := func() {
+		// Migrate instance pins connection to scratch database that would keep
+		// it from being dropped so it's closed first, which closes db as well
+		if scratch.migrateCfg.Migrate != nil {
+			scratch.migrateCfg.Migrate.Close()
+		}
+
+		db.Close()
+
+		if err := cdbmutil.DefaultExecCmd(exec.Command(
+			"/bin/sh",
+			"-c",
+			fmt.Sprintf(settings.DBAction.DropDB, dbName),
+		)); err != nil {
+			cdbm.logger().Warn("can't drop scratch database", "dbname", dbName, "error", err)
+		}
+	}
+
+	return scratch, settings, drop, nil
+}
+
+// migrateScratch migrates scratch database returned by CDBM#newScratch to
+// given version with given migrations
+//
+// Flags that only apply to migrating database of user ie. writing metrics
+// or snapshot files are not used
+func (cdbm *CDBM) migrateScratch(
+	version int,
+	getMigFunc cdbmutil.GetMigrationFunc,
+	fMigFunc cdbmutil.FileMigrationFunc,
+	cMigrations map[int]cdbmutil.CustomMigration,
+) error {
+	cdbm.MigrateFlags.TargetVersion = version
+	cdbm.MigrateFlags.Steps = 0
+	cdbm.MigrateFlags.OnlyTags = nil
+	cdbm.MigrateFlags.RollbackOnFailure = false
+	cdbm.MigrateFlags.Verbose = false
+	cdbm.MigrateFlags.MetricsFile = ""
+	cdbm.MigrateFlags.SnapshotFile = ""
+
+	if err := cdbm.Migrate(getMigFunc, fMigFunc, cMigrations); err != nil {
+		return fmt.Errorf("can't migrate scratch database: %v", err)
+	}
+
+	return nil
+}
+
+// diffSnapshots returns every difference of actual snapshot from expected
+// snapshot ie. "missing index public.users.users_email_idx"
+func diffSnapshots(expected, actual Snapshot) []string {
+	diffs := make([]string, 0)
+	expectedTables := make([]snapshotEntry, 0, len(expected.Tables))
+	actualTables := make(map[string]SnapshotTable, len(actual.Tables))
+	actualEntries := make([]snapshotEntry, 0, len(actual.Tables))
+
+	for _, table := range actual.Tables {
+		name := table.Schema + "." + table.Name
+		actualTables[name] = table
+		actualEntries = append(actualEntries, snapshotEntry{name: name})
+	}
+
+	for _, table := range expected.Tables {
+		expectedTables = append(expectedTables, snapshotEntry{name: table.Schema + "." + table.Name})
+	}
+
+	diffs = append(diffs, diffEntries("table", expectedTables, actualEntries)...)
+
+	for _, table := range expected.Tables {
+		name := table.Schema + "." + table.Name
+		actualTable, ok := actualTables[name]
+
+		if !ok {
+			continue
+		}
+
+		diffs = append(diffs, diffEntries("column", columnEntries(name, table), columnEntries(name, actualTable))...)
+		diffs = append(diffs, diffEntries("constraint", constraintEntries(name, table), constraintEntries(name, actualTable))...)
+		diffs = append(diffs, diffEntries("index", indexEntries(name, table), indexEntries(name, actualTable))...)
+	}
+
+	diffs = append(diffs, diffEntries("view", viewEntries(expected), viewEntries(actual))...)
+	diffs = append(diffs, diffEntries("function", functionEntries(expected), functionEntries(actual))...)
+	return diffs
+}
+
+// diffEntries returns entries of given kind that are missing from actual,
+// unexpected in actual or whose value differs
+//
+// Values are only displayed if they fit on one line
+func diffEntries(kind string, expected, actual []snapshotEntry) []string {
+	diffs := make([]string, 0)
+	actualValues := make(map[string]string, len(actual))
+	expectedNames := make(map[string]bool, len(expected))
+
+	for _, entry := range actual {
+		actualValues[entry.name] = entry.value
+	}
+
+	for _, entry := range expected {
+		expectedNames[entry.name] = true
+		value, ok := actualValues[entry.name]
+
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("missing %s %s", kind, entry.name))
+		case value == entry.value:
+		case strings.Contains(value+entry.value, "\n"):
+			diffs = append(diffs, fmt.Sprintf("%s %s differs", kind, entry.name))
+		default:
+			diffs = append(diffs, fmt.Sprintf("%s %s differs: expected %s, got %s", kind, entry.name, entry.value, value))
+		}
+	}
+
+	for _, entry := range actual {
+		if !expectedNames[entry.name] {
+			diffs = append(diffs, fmt.Sprintf("unexpected %s %s", kind, entry.name))
+		}
+	}
+
+	return diffs
+}
+
+// columnEntries returns columns of given table named table.column
+func columnEntries(tableName string, table SnapshotTable) []snapshotEntry {
+	entries := make([]snapshotEntry, 0, len(table.Columns))
+
+	for _, column := range table.Columns {
+		entries = append(entries, snapshotEntry{
+			name:  tableName + "." + column.Name,
+			value: column.definition(),
+		})
+	}
+
+	return entries
+}
+
+// constraintEntries returns constraints of given table named table.constraint
+func constraintEntries(tableName string, table SnapshotTable) []snapshotEntry {
+	entries := make([]snapshotEntry, 0, len(table.Constraints))
+
+	for _, constraint := range table.Constraints {
+		entries = append(entries, snapshotEntry{
+			name:  tableName + "." + constraint.Name,
+			value: constraint.Definition,
+		})
+	}
+
+	return entries
+}
+
+// indexEntries returns indexes of given table named table.index
+func indexEntries(tableName string, table SnapshotTable) []snapshotEntry {
+	entries := make([]snapshotEntry, 0, len(table.Indexes))
+
+	for _, index := range table.Indexes {
+		entries = append(entries, snapshotEntry{
+			name:  tableName + "." + index.Name,
+			value: index.Definition,
+		})
+	}
+
+	return entries
+}
+
+// viewEntries returns views of given snapshot named schema.view
+func viewEntries(s Snapshot) []snapshotEntry {
+	entries := make([]snapshotEntry, 0, len(s.Views))
+
+	for _, view := range s.Views {
+		entries = append(entries, snapshotEntry{
+			name:  view.Schema + "." + view.Name,
+			value: view.Definition,
+		})
+	}
+
+	return entries
+}
+
+// functionEntries returns functions of given snapshot named schema.function(arguments)
+func functionEntries(s Snapshot) []snapshotEntry {
+	entries := make([]snapshotEntry, 0, len(s.Functions))
+
+	for _, function := range s.Functions {
+		entries = append(entries, snapshotEntry{
+			name:  fmt.Sprintf("%s.%s(%s)", function.Schema, function.Name, function.Arguments),
+			value: fmt.Sprintf("returns %s language %s\n%s", function.Result, function.Language, function.Body),
+		})
+	}
+
+	return entries
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	expected := Snapshot{
+		Tables: []SnapshotTable{
+			{
+				Schema: "public",
+				Name:   "users",
+				Columns: []SnapshotColumn{
+					{Name: "id", Type: "bigint"},
+					{Name: "email", Type: "character varying(255)", Nullable: true},
+				},
+				Constraints: []SnapshotConstraint{
+					{Name: "users_pkey", Type: "primary key", Definition: "PRIMARY KEY (id)"},
+				},
+				Indexes: []SnapshotIndex{
+					{Name: "users_email_idx", Definition: "CREATE INDEX users_email_idx ON public.users USING btree (email)"},
+				},
+			},
+			{
+				Schema: "public",
+				Name:   "orders",
+			},
+		},
+		Views: []SnapshotView{
+			{Schema: "public", Name: "user_emails", Definition: "SELECT users.email\n   FROM users;"},
+		},
+		Functions: []SnapshotFunction{
+			{Schema: "public", Name: "add", Arguments: "a integer, b integer", Result: "integer", Language: "sql", Body: "select a + b"},
+		},
+	}
+
+	// Validating that same snapshots have no differences
+	if diffs := diffSnapshots(expected, expected); len(diffs) != 0 {
+		t.Errorf("should not have differences; got %v", diffs)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that missing, unexpected and changed entries are reported
+	actual := Snapshot{
+		Tables: []SnapshotTable{
+			{
+				Schema: "public",
+				Name:   "users",
+				Columns: []SnapshotColumn{
+					{Name: "id", Type: "bigint"},
+					{Name: "email", Type: "character varying(100)", Nullable: true},
+					{Name: "hotfix", Type: "text", Nullable: true},
+				},
+				Constraints: []SnapshotConstraint{
+					{Name: "users_pkey", Type: "primary key", Definition: "PRIMARY KEY (id)"},
+				},
+			},
+			{
+				Schema: "public",
+				Name:   "tmp_backup",
+			},
+		},
+		Views: []SnapshotView{
+			{Schema: "public", Name: "user_emails", Definition: "SELECT users.email,\n    users.id\n   FROM users;"},
+		},
+	}
+
+	expectedDiffs := []string{
+		"missing table public.orders",
+		"unexpected table public.tmp_backup",
+		"column public.users.email differs: expected character varying(255), got character varying(100)",
+		"unexpected column public.users.hotfix",
+		"missing index public.users.users_email_idx",
+		"view public.user_emails differs",
+		"missing function public.add(a integer, b integer)",
+	}
+
+	if diffs := diffSnapshots(expected, actual); !reflect.DeepEqual(diffs, expectedDiffs) {
+		t.Errorf("should have differences %q; got %q", expectedDiffs, diffs)
+	}
+}
+
+func TestNotAppliedVersions(t *testing.T) {
+	cfgs := []migrationApplyConfig{
+		{Version: 1},
+		{Version: 2},
+		{Version: 3},
+		{Version: 4},
+		{Version: 5},
+	}
+
+	// Validating that skipped versions and versions missing from applied
+	// versions up to current version are not applied
+	notApplied := notAppliedVersions(
+		cfgs,
+		4,
+		map[int]bool{1: true, 3: true},
+		true,
+		map[int]bool{4: true},
+	)
+
+	if !reflect.DeepEqual(sortedVersions(notApplied), []int{2, 4}) {
+		t.Errorf("should have versions [2 4] not applied; got %v", sortedVersions(notApplied))
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that every version is applied if schema_migrations_applied
+	// table doesn't exist
+	if notApplied = notAppliedVersions(cfgs, 4, nil, false, nil); len(notApplied) != 0 {
+		t.Errorf("should have every version applied; got %v", sortedVersions(notApplied))
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that versions not applied are skipped when migrating
+	c := &CDBM{skipVersions: map[int]bool{2: true}}
+
+	if !c.isSkippedByTags(migrationApplyConfig{Version: 2}) {
+		t.Errorf("should skip version 2")
+	}
+	if c.isSkippedByTags(migrationApplyConfig{Version: 3}) {
+		t.Errorf("should not skip version 3")
+	}
+}
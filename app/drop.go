@@ -24,9 +24,12 @@ func (cdbm *CDBM) Drop() error {
 		return errors.WithStack(err)
 	}
 
-	if err = mig.Drop(); err == nil {
-		fmt.Printf("All tables dropped\n")
+	if err = mig.Drop(); err != nil {
+		cdbm.logger().Error("can't drop tables", "host", cdbm.currentDBSettings.Host, "error", err)
+		return errors.WithStack(err)
 	}
 
-	return errors.WithStack(err)
+	cdbm.logger().Info("dropped all tables", "host", cdbm.currentDBSettings.Host, "dbname", cdbm.currentDBSettings.DBName)
+	fmt.Printf("All tables dropped\n")
+	return nil
 }
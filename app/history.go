@@ -0,0 +1,69 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// History will print out to stdout every version recorded as applied in the
+// schema_migrations_applied table along with description of custom migrations
+func (cdbm *CDBM) History() error {
+	var err error
+
+	if err = cdbm.DBProtocolCfg.AppliedTableSearch(cdbm.DB); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Printf("No migration history\n")
+			return nil
+		}
+
+		return errors.WithStack(err)
+	}
+
+	rows, err := cdbm.DB.Queryx(
+		`
+		select
+			schema_migrations_applied.version,
+			schema_migrations_applied.is_custom_migration,
+			schema_migrations_applied.applied_at
+		from
+			schema_migrations_applied
+		order by
+			schema_migrations_applied.version
+		`,
+	)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var isCustom bool
+		var appliedAt time.Time
+
+		if err = rows.Scan(&version, &isCustom, &appliedAt); err != nil {
+			return errors.WithStack(err)
+		}
+
+		kind := "file"
+
+		if isCustom {
+			kind = "custom"
+		}
+
+		fmt.Printf(
+			"version:%d / kind:%s / applied at:%s / description:%s\n",
+			version,
+			kind,
+			appliedAt.UTC().Format(time.RFC3339),
+			cdbm.CustomMigrations[version].Description,
+		)
+	}
+
+	return errors.WithStack(rows.Err())
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+)
+
+func ExampleCDBM_History_a() {
+	db, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		fmt.Printf("%+v", err)
+		return
+	}
+
+	dbpCfg := cdbmutil.DefaultProtocolMap[cdbmutil.CockroachdbProtocol]
+	dbpCfg.AppliedTableSearch = func(db webutil.DBInterface) error {
+		return sql.ErrNoRows
+	}
+
+	cdbm := &CDBM{
+		DB:            sqlx.NewDb(db, webutil.Postgres),
+		DBProtocolCfg: dbpCfg,
+	}
+
+	if err = cdbm.History(); err != nil {
+		fmt.Printf("%+v", err)
+		return
+	}
+
+	// Output: No migration history
+}
+
+func ExampleCDBM_History_b() {
+	db, mockDB, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		fmt.Printf("%+v", err)
+		return
+	}
+
+	dbpCfg := cdbmutil.DefaultProtocolMap[cdbmutil.CockroachdbProtocol]
+	dbpCfg.AppliedTableSearch = func(db webutil.DBInterface) error {
+		return nil
+	}
+
+	cdbm := &CDBM{
+		DB:            sqlx.NewDb(db, webutil.Postgres),
+		DBProtocolCfg: dbpCfg,
+		CustomMigrations: map[int]cdbmutil.CustomMigration{
+			2: {Description: "backfills names"},
+		},
+	}
+
+	appliedAt := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"version", "is_custom_migration", "applied_at"}).
+			AddRow(1, false, appliedAt).
+			AddRow(2, true, appliedAt.Add(time.Hour)),
+	)
+
+	if err = cdbm.History(); err != nil {
+		fmt.Printf("%+v", err)
+		return
+	}
+
+	// Output:
+	// version:1 / kind:file / applied at:2021-03-04T05:06:07Z / description:
+	// version:2 / kind:custom / applied at:2021-03-04T06:06:07Z / description:backfills names
+}
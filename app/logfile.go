@@ -0,0 +1,236 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// logBackupTimeLayout is layout of timestamp appended to rotated log files
+	// ie. cdbm.log.20210102T150405.000Z
+	logBackupTimeLayout = "20060102T150405.000Z"
+
+	// megabyte is used to convert LogFlagsConfig#LogMaxSize to bytes
+	megabyte = 1024 * 1024
+)
+
+// rotatingFile is log file opened for append that is rotated once it reaches
+// max size or its first entry reaches max age, keeping max backups of rotated files
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	file    *os.File
+	size    int64
+	started time.Time
+}
+
+// openRotatingFile creates directory of given path if it doesn't exist and
+// opens file at path for append, rotating it first if needed
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	if r.shouldRotate(0) {
+		if err := r.rotate(); err != nil {
+			r.file.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// open opens log file for append and sets its size and time of first entry
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return errors.WithStack(err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.started = time.Now()
+
+	if r.size > 0 {
+		r.started = firstEntryTime(r.path, info.ModTime())
+	}
+
+	return nil
+}
+
+// shouldRotate determines whether log file must be rotated before writing
+// given number of bytes
+func (r *rotatingFile) shouldRotate(n int) bool {
+	if r.size == 0 {
+		return false
+	}
+
+	if r.maxSize > 0 && r.size+int64(n) > r.maxSize {
+		return true
+	}
+
+	return r.maxAge > 0 && time.Since(r.started) > r.maxAge
+}
+
+// Write writes given bytes to log file, rotating it first if needed
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, fmt.Errorf("log file '%s' is closed", r.path)
+	}
+
+	if r.shouldRotate(len(p)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	if err != nil {
+		return n, errors.WithStack(err)
+	}
+
+	return n, nil
+}
+
+// Close closes log file
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+	return errors.WithStack(err)
+}
+
+// rotate renames current log file with timestamp appended, opens new log file
+// and removes oldest backups past max backups
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	r.file = nil
+	backup := r.path + "." + time.Now().UTC().Format(logBackupTimeLayout)
+
+	if err := os.Rename(r.path, backup); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	return r.removeOldBackups()
+}
+
+// removeOldBackups removes oldest rotated log files past max backups
+//
+// If max backups is 0, every backup is kept
+func (r *rotatingFile) removeOldBackups() error {
+	if r.maxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := logBackups(r.path)
+
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > r.maxBackups {
+		if err = os.Remove(backups[0]); err != nil {
+			return errors.WithStack(err)
+		}
+
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// logBackups returns rotated log files of given log file path from oldest to newest
+func logBackups(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	backups := make([]string, 0, len(matches))
+
+	for _, match := range matches {
+		if _, err = time.Parse(logBackupTimeLayout, strings.TrimPrefix(match, path+".")); err == nil {
+			backups = append(backups, match)
+		}
+	}
+
+	// Timestamp layout sorts in time order
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// firstEntryTime returns time of first entry of given log file or given
+// default time if it can't be read
+func firstEntryTime(path string, defaultTime time.Time) time.Time {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return defaultTime
+	}
+
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+
+	if err != nil && err != io.EOF {
+		return defaultTime
+	}
+
+	if entry, ok := parseLogEntry(line); ok {
+		return entry.time
+	}
+
+	return defaultTime
+}
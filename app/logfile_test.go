@@ -0,0 +1,133 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	var err error
+
+	dir, err := ioutil.TempDir("", "cdbm-log")
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logs", "cdbm.log")
+
+	// Validating that existing log file is appended to
+	for _, line := range []string{"first\n", "second\n"} {
+		r, err := openRotatingFile(path, 0, 0, 0)
+
+		if err != nil {
+			t.Fatalf("should not have error; got %+v", err)
+		}
+
+		if _, err = r.Write([]byte(line)); err != nil {
+			t.Fatalf("should not have error; got %+v", err)
+		}
+
+		r.Close()
+	}
+
+	if b, _ := ioutil.ReadFile(path); string(b) != "first\nsecond\n" {
+		t.Errorf("should have appended to log file; got %q\n", string(b))
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that log file is rotated by size and old backups are removed
+	r, err := openRotatingFile(path, 20, 0, 1)
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err = r.Write([]byte("0123456789\n")); err != nil {
+			t.Fatalf("should not have error; got %+v", err)
+		}
+
+		// Backup names have millisecond precision
+		time.Sleep(time.Millisecond * 2)
+	}
+
+	backups, err := logBackups(path)
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if len(backups) != 1 {
+		t.Errorf("should have kept 1 backup; got %v\n", backups)
+	}
+
+	if b, _ := ioutil.ReadFile(path); string(b) != "0123456789\n" {
+		t.Errorf("should have started new log file; got %q\n", string(b))
+	}
+
+	r.Close()
+
+	// --------------------------------------------------------------------------
+
+	// Validating that log file is rotated by age of its first entry
+	if err = ioutil.WriteFile(
+		path,
+		[]byte("time="+time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano)+" level=info msg=old\n"),
+		0644,
+	); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	time.Sleep(time.Millisecond * 2)
+
+	if r, err = openRotatingFile(path, 0, time.Minute, 0); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	r.Close()
+
+	if b, _ := ioutil.ReadFile(path); len(b) != 0 {
+		t.Errorf("should have rotated old log file; got %q\n", string(b))
+	}
+}
+
+func TestLogWriteFailed(t *testing.T) {
+	var err error
+
+	dir, err := ioutil.TempDir("", "cdbm-log")
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	c := &CDBM{
+		LogFlags: LogFlagsConfig{
+			LogFile: filepath.Join(dir, "cdbm.log"),
+		},
+		RootFlags: RootFlagsConfig{
+			LogLevel: "error",
+		},
+	}
+
+	if err = c.initLogger(); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	// Validating that failed writes are returned when log file is closed
+	c.logFile.file.Close()
+	c.Logger.Error("migration failed")
+
+	if err = c.CloseLogFile(); err == nil || !strings.Contains(err.Error(), "can't write to log file") {
+		t.Errorf("should have log file write error; got %v\n", err)
+	}
+}
@@ -0,0 +1,338 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// LogFormatText writes log entries as key=value pairs ie.
+	// time=2021-01-01T00:00:00Z level=info msg="applied migration" version=2
+	LogFormatText = "text"
+
+	// LogFormatJSON writes log entries as one json object per line
+	LogFormatJSON = "json"
+)
+
+// LogLevel is severity of log entry
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// ValidLogLevels are valid values for RootFlagsConfig#LogLevel
+var ValidLogLevels = []string{"debug", "info", "warn", "error"}
+
+// ValidLogFormats are valid values for RootFlagsConfig#LogFormat
+var ValidLogFormats = []string{LogFormatText, LogFormatJSON}
+
+// String returns name of log level ie. "info"
+func (l LogLevel) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+
+	return ValidLogLevels[l]
+}
+
+// ParseLogLevel returns LogLevel of given name
+//
+// Empty name returns LevelInfo
+func ParseLogLevel(name string) (LogLevel, error) {
+	if name == "" {
+		return LevelInfo, nil
+	}
+
+	for i, level := range ValidLogLevels {
+		if strings.EqualFold(name, level) {
+			return LogLevel(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid log level '%s'.  Valid log levels are: %v", name, ValidLogLevels)
+}
+
+// Logger is leveled logger used by cdbm commands
+//
+// Fields are alternating key and value pairs ie. "version", 2, "direction", "up"
+type Logger interface {
+	Debug(msg string, fields ...interface{})
+	Info(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+
+	// With returns Logger that adds given fields to every entry
+	With(fields ...interface{}) Logger
+}
+
+// NewLogger returns Logger that writes every entry at or above given level to
+// each of given sinks in given format
+func NewLogger(level LogLevel, format string, sinks ...io.Writer) (Logger, error) {
+	if format == "" {
+		format = LogFormatText
+	}
+
+	if format != LogFormatText && format != LogFormatJSON {
+		return nil, fmt.Errorf("invalid log format '%s'.  Valid log formats are: %v", format, ValidLogFormats)
+	}
+
+	return &logger{
+		out: &logOutput{
+			level:  level,
+			format: format,
+			sinks:  sinks,
+		},
+	}, nil
+}
+
+// logOutput is shared by every logger returned by Logger#With
+type logOutput struct {
+	mu     sync.Mutex
+	level  LogLevel
+	format string
+	sinks  []io.Writer
+	redact func(string) string
+
+	// onWriteErr is called with sink and error of every failed write if set
+	onWriteErr func(io.Writer, error)
+}
+
+type logger struct {
+	out    *logOutput
+	fields []interface{}
+}
+
+func (l *logger) Debug(msg string, fields ...interface{}) {
+	l.log(LevelDebug, msg, fields)
+}
+
+func (l *logger) Info(msg string, fields ...interface{}) {
+	l.log(LevelInfo, msg, fields)
+}
+
+func (l *logger) Warn(msg string, fields ...interface{}) {
+	l.log(LevelWarn, msg, fields)
+}
+
+func (l *logger) Error(msg string, fields ...interface{}) {
+	l.log(LevelError, msg, fields)
+}
+
+func (l *logger) With(fields ...interface{}) Logger {
+	return &logger{
+		out:    l.out,
+		fields: append(append([]interface{}{}, l.fields...), fields...),
+	}
+}
+
+func (l *logger) log(level LogLevel, msg string, fields []interface{}) {
+	if level < l.out.level {
+		return
+	}
+
+	line := formatLogEntry(
+		l.out.format,
+		time.Now().UTC(),
+		level,
+		msg,
+		append(append([]interface{}{}, l.fields...), fields...),
+	)
+
+	if l.out.redact != nil {
+		line = l.out.redact(line)
+	}
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+
+	for _, sink := range l.out.sinks {
+		if _, err := io.WriteString(sink, line); err != nil && l.out.onWriteErr != nil {
+			l.out.onWriteErr(sink, err)
+		}
+	}
+}
+
+// formatLogEntry returns log entry as line in given format
+//
+// A field without value is given key "!BADKEY"
+func formatLogEntry(format string, t time.Time, level LogLevel, msg string, fields []interface{}) string {
+	type pair struct {
+		key   string
+		value interface{}
+	}
+
+	pairs := []pair{
+		{"time", t.Format(time.RFC3339Nano)},
+		{"level", level.String()},
+		{"msg", msg},
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 == len(fields) {
+			pairs = append(pairs, pair{"!BADKEY", logValue(fields[i])})
+			break
+		}
+
+		pairs = append(pairs, pair{fmt.Sprint(fields[i]), logValue(fields[i+1])})
+	}
+
+	if format == LogFormatJSON {
+		entry := make(map[string]interface{}, len(pairs))
+
+		for _, p := range pairs {
+			entry[p.key] = p.value
+		}
+
+		b, err := json.Marshal(entry)
+
+		if err != nil {
+			b, _ = json.Marshal(map[string]interface{}{
+				"time":  pairs[0].value,
+				"level": pairs[1].value,
+				"msg":   msg,
+				"error": "can't marshal log entry: " + err.Error(),
+			})
+		}
+
+		return string(b) + "\n"
+	}
+
+	parts := make([]string, 0, len(pairs))
+
+	for _, p := range pairs {
+		value := fmt.Sprint(p.value)
+
+		if value == "" || strings.ContainsAny(value, " =\"\n\t") {
+			value = strconv.Quote(value)
+		}
+
+		parts = append(parts, p.key+"="+value)
+	}
+
+	return strings.Join(parts, " ") + "\n"
+}
+
+// logValue returns value that formats well in both log formats
+func logValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	return value
+}
+
+// nopLogger discards every entry and is used when CDBM#Logger is not set
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...interface{}) {}
+func (nopLogger) Info(msg string, fields ...interface{})  {}
+func (nopLogger) Warn(msg string, fields ...interface{})  {}
+func (nopLogger) Error(msg string, fields ...interface{}) {}
+func (n nopLogger) With(fields ...interface{}) Logger     { return n }
+
+// logger returns CDBM#Logger or Logger that discards every entry if not set
+func (cdbm *CDBM) logger() Logger {
+	if cdbm.Logger == nil {
+		return nopLogger{}
+	}
+
+	return cdbm.Logger
+}
+
+// initLogger sets CDBM#Logger, if not set by user, to Logger based on
+// RootFlagsConfig#LogLevel and RootFlagsConfig#LogFormat that writes to stderr
+// and LogFlagsConfig#LogFile if set
+//
+// Logger is built again if LogFlagsConfig#LogFile changed since last call
+// so commands can set log file after CDBM is initiated
+func (cdbm *CDBM) initLogger() error {
+	if cdbm.Logger != nil && !cdbm.defaultLogger {
+		return nil
+	}
+
+	if cdbm.defaultLogger && cdbm.logFilePath == cdbm.LogFlags.LogFile {
+		return nil
+	}
+
+	level, err := ParseLogLevel(cdbm.RootFlags.LogLevel)
+
+	if err != nil {
+		return err
+	}
+
+	sinks := []io.Writer{os.Stderr}
+
+	logFile, err := cdbm.createLogsDirectory()
+
+	if err != nil {
+		return err
+	}
+
+	if logFile != nil {
+		sinks = append(sinks, logFile)
+	}
+
+	l, err := NewLogger(level, cdbm.RootFlags.LogFormat, sinks...)
+
+	if err != nil {
+		if logFile != nil {
+			logFile.Close()
+		}
+
+		return err
+	}
+
+	l.(*logger).out.redact = cdbm.redact
+	l.(*logger).out.onWriteErr = cdbm.logWriteFailed
+
+	if cdbm.logFile != nil {
+		cdbm.logFile.Close()
+	}
+
+	cdbm.Logger = l
+	cdbm.logFile = logFile
+	cdbm.logFilePath = cdbm.LogFlags.LogFile
+	cdbm.defaultLogger = true
+	return nil
+}
+
+// logWriteFailed keeps track of first error writing to log file and displays
+// it to stderr so entries are not lost silently
+func (cdbm *CDBM) logWriteFailed(sink io.Writer, err error) {
+	if sink != io.Writer(cdbm.logFile) || cdbm.logWriteErr != nil {
+		return
+	}
+
+	cdbm.logWriteErr = fmt.Errorf("can't write to log file '%s': %v", cdbm.logFilePath, err)
+	fmt.Fprintln(os.Stderr, cdbm.logWriteErr.Error())
+}
+
+// CloseLogFile closes log file opened for CDBM#Logger, if any, and returns
+// error if any log entry failed to be written to it
+func (cdbm *CDBM) CloseLogFile() error {
+	if cdbm.logFile == nil {
+		return nil
+	}
+
+	err := cdbm.logFile.Close()
+	cdbm.logFile = nil
+
+	if cdbm.logWriteErr != nil {
+		return cdbm.logWriteErr
+	}
+
+	return err
+}
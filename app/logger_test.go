@@ -0,0 +1,90 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// errorLogger is Logger that calls itself with error field of every error entry
+type errorLogger func(error)
+
+func (f errorLogger) Debug(msg string, fields ...interface{}) {}
+func (f errorLogger) Info(msg string, fields ...interface{})  {}
+func (f errorLogger) Warn(msg string, fields ...interface{})  {}
+func (f errorLogger) With(fields ...interface{}) Logger       { return f }
+
+func (f errorLogger) Error(msg string, fields ...interface{}) {
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "error" {
+			err, _ := fields[i+1].(error)
+			f(err)
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var err error
+
+	var textBuf, jsonBuf bytes.Buffer
+
+	// Validating that entries below level are dropped and fields are formatted
+	l, err := NewLogger(LevelInfo, LogFormatText, &textBuf, &jsonBuf)
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	l.Debug("dropped")
+	l.With("version", 2).Info("applied migration", "duration", time.Second, "host", "db 1")
+
+	if strings.Contains(textBuf.String(), "dropped") {
+		t.Errorf("should not have written debug entry; got %s\n", textBuf.String())
+	}
+
+	if !strings.HasSuffix(
+		textBuf.String(),
+		` level=info msg="applied migration" version=2 duration=1s host="db 1"`+"\n",
+	) {
+		t.Errorf("invalid text entry; got %s\n", textBuf.String())
+	}
+
+	if textBuf.String() != jsonBuf.String() {
+		t.Errorf("should have written same entry to every sink")
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating json format
+	jsonBuf.Reset()
+
+	if l, err = NewLogger(LevelDebug, LogFormatJSON, &jsonBuf); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	l.Error("migration failed", "version", 3, "error", errors.New("syntax error"))
+
+	entry := make(map[string]interface{})
+
+	if err = json.Unmarshal(jsonBuf.Bytes(), &entry); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if entry["level"] != "error" || entry["version"] != float64(3) || entry["error"] != "syntax error" {
+		t.Errorf("invalid json entry; got %v\n", entry)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating invalid level and format
+	if _, err = ParseLogLevel("verbose"); err == nil {
+		t.Errorf("should have error for invalid level")
+	}
+
+	if _, err = NewLogger(LevelInfo, "xml"); err == nil {
+		t.Errorf("should have error for invalid format")
+	}
+}
@@ -1,25 +1,443 @@
 package app
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"os/exec"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
+// logsFollowInterval is how often log file is checked for new entries with
+// LogFlagsConfig#Follow
+var logsFollowInterval = time.Millisecond * 500
+
 // LogFlagsConfig is config struct used for logging settings for CDBM#Logs function
 type LogFlagsConfig struct {
 	// LogFile should the the directory
 	LogFile string `yaml:"log_file" mapstructure:"log_file"`
+
+	// LogMaxSize is size in megabytes log file can reach before it is rotated
+	//
+	// If 0, log file is not rotated by size
+	LogMaxSize int `yaml:"log_max_size" mapstructure:"log_max_size"`
+
+	// LogMaxAge is how old first entry of log file can be before it is rotated
+	//
+	// If 0, log file is not rotated by age
+	LogMaxAge time.Duration `yaml:"log_max_age" mapstructure:"log_max_age"`
+
+	// LogMaxBackups is number of rotated log files to keep
+	//
+	// If 0, every rotated log file is kept
+	LogMaxBackups int `yaml:"log_max_backups" mapstructure:"log_max_backups"`
+
+	// Below are filters of CDBM#Logs which are only set by logs command flags
+
+	// Since only displays entries at or after given time which is either
+	// RFC3339 timestamp or duration before now ie. 2021-01-02T15:04:05Z or 24h
+	Since string `yaml:"-" mapstructure:"-"`
+
+	// Until only displays entries at or before given time in same format as Since
+	Until string `yaml:"-" mapstructure:"-"`
+
+	// Version only displays entries with given migration version if above 0
+	Version int `yaml:"-" mapstructure:"-"`
+
+	// Level only displays entries at or above given level ie. warn
+	Level string `yaml:"-" mapstructure:"-"`
+
+	// Tail only displays last given number of matching entries if above 0
+	Tail int `yaml:"-" mapstructure:"-"`
+
+	// Follow keeps displaying new entries as they are written until interrupted
+	Follow bool `yaml:"-" mapstructure:"-"`
+}
+
+// logEntry is parsed line of log file
+type logEntry struct {
+	time   time.Time
+	level  LogLevel
+	fields map[string]string
+}
+
+// logsFilter is parsed filters of LogFlagsConfig
+type logsFilter struct {
+	since   time.Time
+	until   time.Time
+	version int
+	level   LogLevel
+}
+
+// isSet determines whether any filter that requires structured entries is set
+func (f logsFilter) isSet() bool {
+	return !f.since.IsZero() || !f.until.IsZero() || f.version > 0 || f.level > LevelDebug
+}
+
+// match determines whether given line passes filter
+//
+// Lines that are not structured entries only pass if no filter is set
+func (f logsFilter) match(line []byte) bool {
+	if !f.isSet() {
+		return true
+	}
+
+	entry, ok := parseLogEntry(line)
+
+	if !ok {
+		return false
+	}
+
+	if !f.since.IsZero() && entry.time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && entry.time.After(f.until) {
+		return false
+	}
+	if f.version > 0 && entry.fields["version"] != strconv.Itoa(f.version) {
+		return false
+	}
+
+	return entry.level >= f.level
+}
+
+// Logs displays entries of log file and its rotated backups, oldest first,
+// that match filters of LogFlagsConfig
+//
+// Log file and filters are loaded from config and given log flags without
+// connecting to database
+func Logs(cfg RootFlagsConfig, logFlags LogFlagsConfig) error {
+	cdbm, err := loadCDBM(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	if err = cdbm.applyTarget(); err != nil {
+		return err
+	}
+
+	if logFlags.LogFile != "" {
+		cdbm.LogFlags.LogFile = logFlags.LogFile
+	}
+
+	cdbm.LogFlags.Since = logFlags.Since
+	cdbm.LogFlags.Until = logFlags.Until
+	cdbm.LogFlags.Version = logFlags.Version
+	cdbm.LogFlags.Level = logFlags.Level
+	cdbm.LogFlags.Tail = logFlags.Tail
+	cdbm.LogFlags.Follow = logFlags.Follow
+
+	return cdbm.Logs()
 }
 
 // Logs function will simply display log information written to log log file
+//
+// Entries are filtered by LogFlagsConfig#Since, LogFlagsConfig#Until,
+// LogFlagsConfig#Version and LogFlagsConfig#Level and if LogFlagsConfig#Follow
+// is set, new entries keep being displayed until interrupted
 func (cdbm *CDBM) Logs() error {
-	catCmd := exec.Command("cat", cdbm.LogFlags.LogFile)
-	fileBytes, err := catCmd.Output()
+	return cdbm.writeLogs(os.Stdout, nil)
+}
+
+// writeLogs writes entries of log file to given writer and, if following,
+// keeps writing new entries until stop is closed
+func (cdbm *CDBM) writeLogs(w io.Writer, stop <-chan struct{}) error {
+	if cdbm.LogFlags.LogFile == "" {
+		return fmt.Errorf("log file is not set.  Set --log-file or log_flags.log_file in config file")
+	}
+
+	filter, err := cdbm.logsFilter()
+
+	if err != nil {
+		return err
+	}
+
+	paths, err := logBackups(cdbm.LogFlags.LogFile)
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf(string(fileBytes) + "\n")
-	return nil
+	paths = append(paths, cdbm.LogFlags.LogFile)
+	lines := make([][]byte, 0)
+
+	for i, path := range paths {
+		file, err := os.Open(path)
+
+		if err != nil {
+			// Log file may not have been written to yet if following
+			if i == len(paths)-1 && errors.Is(err, os.ErrNotExist) && cdbm.LogFlags.Follow {
+				break
+			}
+
+			return errors.WithStack(err)
+		}
+
+		reader := bufio.NewReader(file)
+
+		for {
+			line, err := reader.ReadBytes('\n')
+
+			if len(line) > 0 && filter.match(line) {
+				lines = append(lines, line)
+
+				if cdbm.LogFlags.Tail > 0 && len(lines) > cdbm.LogFlags.Tail {
+					lines = lines[1:]
+				}
+			}
+
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				file.Close()
+				return errors.WithStack(err)
+			}
+		}
+
+		file.Close()
+	}
+
+	for _, line := range lines {
+		if err = writeLogLine(w, line); err != nil {
+			return err
+		}
+	}
+
+	if !cdbm.LogFlags.Follow {
+		return nil
+	}
+
+	return cdbm.followLogs(w, filter, stop)
+}
+
+// followLogs writes new entries of log file to given writer as they are
+// written until stop is closed, reopening log file when it is rotated
+func (cdbm *CDBM) followLogs(w io.Writer, filter logsFilter, stop <-chan struct{}) error {
+	var file *os.File
+	var reader *bufio.Reader
+	var offset int64
+
+	info, err := os.Stat(cdbm.LogFlags.LogFile)
+
+	if err == nil {
+		offset = info.Size()
+	}
+
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	pending := make([]byte, 0)
+
+	for {
+		info, err = os.Stat(cdbm.LogFlags.LogFile)
+
+		// If log file was rotated, start reading new log file from beginning
+		if err == nil && file != nil {
+			if current, statErr := file.Stat(); statErr == nil && (!os.SameFile(info, current) || info.Size() < offset) {
+				file.Close()
+				file = nil
+				offset = 0
+			}
+		}
+
+		if err == nil && file == nil {
+			if file, err = os.Open(cdbm.LogFlags.LogFile); err != nil {
+				return errors.WithStack(err)
+			}
+
+			if _, err = file.Seek(offset, io.SeekStart); err != nil {
+				return errors.WithStack(err)
+			}
+
+			reader = bufio.NewReader(file)
+		}
+
+		if file != nil {
+			for {
+				line, err := reader.ReadBytes('\n')
+				offset += int64(len(line))
+				pending = append(pending, line...)
+
+				// Partial lines are kept until rest of line is written
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return errors.WithStack(err)
+				}
+
+				if filter.match(pending) {
+					if err = writeLogLine(w, pending); err != nil {
+						return err
+					}
+				}
+
+				pending = pending[:0]
+			}
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(logsFollowInterval):
+		}
+	}
+}
+
+// logsFilter returns parsed filters of LogFlagsConfig
+func (cdbm *CDBM) logsFilter() (logsFilter, error) {
+	var err error
+	var filter logsFilter
+
+	if filter.since, err = parseLogsTime(cdbm.LogFlags.Since); err != nil {
+		return filter, fmt.Errorf("invalid --since: %v", err)
+	}
+	if filter.until, err = parseLogsTime(cdbm.LogFlags.Until); err != nil {
+		return filter, fmt.Errorf("invalid --until: %v", err)
+	}
+
+	filter.version = cdbm.LogFlags.Version
+	filter.level = LevelDebug
+
+	if cdbm.LogFlags.Level != "" {
+		if filter.level, err = ParseLogLevel(cdbm.LogFlags.Level); err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+// parseLogsTime parses given RFC3339 timestamp or duration before now
+//
+// Empty value returns zero time
+func parseLogsTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' must be RFC3339 timestamp or duration ie. 2021-01-02T15:04:05Z or 24h", value)
+	}
+
+	return t, nil
+}
+
+// writeLogLine writes given line to writer with trailing newline
+func writeLogLine(w io.Writer, line []byte) error {
+	if !bytes.HasSuffix(line, []byte("\n")) {
+		line = append(line, '\n')
+	}
+
+	_, err := w.Write(line)
+	return errors.WithStack(err)
+}
+
+// parseLogEntry parses given line written in either LogFormatText or LogFormatJSON
+func parseLogEntry(line []byte) (logEntry, bool) {
+	var entry logEntry
+
+	fields := make(map[string]string)
+	line = bytes.TrimSpace(line)
+
+	if bytes.HasPrefix(line, []byte("{")) {
+		values := make(map[string]interface{})
+
+		if err := json.Unmarshal(line, &values); err != nil {
+			return entry, false
+		}
+
+		for k, v := range values {
+			fields[k] = fmt.Sprint(v)
+		}
+	} else {
+		s := string(line)
+
+		for s != "" {
+			eq := strings.IndexByte(s, '=')
+
+			if eq <= 0 {
+				return entry, false
+			}
+
+			key := s[:eq]
+			s = s[eq+1:]
+
+			var value string
+
+			if strings.HasPrefix(s, `"`) {
+				end := quotedEnd(s)
+
+				if end < 0 {
+					return entry, false
+				}
+
+				var err error
+
+				if value, err = strconv.Unquote(s[:end]); err != nil {
+					return entry, false
+				}
+
+				s = s[end:]
+			} else if sp := strings.IndexByte(s, ' '); sp >= 0 {
+				value = s[:sp]
+				s = s[sp:]
+			} else {
+				value = s
+				s = ""
+			}
+
+			fields[key] = value
+			s = strings.TrimLeft(s, " ")
+		}
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, fields["time"])
+
+	if err != nil {
+		return entry, false
+	}
+
+	level, err := ParseLogLevel(fields["level"])
+
+	if err != nil || fields["level"] == "" {
+		return entry, false
+	}
+
+	entry.time = t
+	entry.level = level
+	entry.fields = fields
+	return entry, true
+}
+
+// quotedEnd returns index right after closing quote of quoted string at start
+// of given string or -1 if it is not closed
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return -1
 }
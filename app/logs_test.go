@@ -0,0 +1,139 @@
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteLogs(t *testing.T) {
+	var err error
+
+	dir, err := ioutil.TempDir("", "cdbm-logs")
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cdbm.log")
+	now := time.Now().UTC()
+
+	if err = ioutil.WriteFile(path+"."+now.Add(-time.Hour*2).Format(logBackupTimeLayout), []byte(
+		`time=`+now.Add(-time.Hour*2).Format(time.RFC3339Nano)+` level=error msg="migration failed" version=1 error="100% broken"`+"\n",
+	), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if err = ioutil.WriteFile(path, []byte(
+		"2021-01-02 15:04:05: old unstructured entry\n"+
+			`time=`+now.Add(-time.Minute).Format(time.RFC3339Nano)+` level=info msg="applied migration" version=2`+"\n"+
+			`{"time":"`+now.Format(time.RFC3339Nano)+`","level":"warn","msg":"slow migration","version":2}`+"\n",
+	), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	writeLogs := func(flags LogFlagsConfig) string {
+		var buf bytes.Buffer
+
+		flags.LogFile = path
+		c := &CDBM{LogFlags: flags}
+
+		if err := c.writeLogs(&buf, nil); err != nil {
+			t.Fatalf("should not have error; got %+v", err)
+		}
+
+		return buf.String()
+	}
+
+	// Validating that every line is displayed from oldest file without filters
+	out := writeLogs(LogFlagsConfig{})
+
+	if strings.Count(out, "\n") != 4 || !strings.HasPrefix(out, "time=") || !strings.Contains(out, "100% broken") {
+		t.Errorf("should have displayed every line; got %s\n", out)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating filters
+	filters := []struct {
+		flags LogFlagsConfig
+		msgs  []string
+	}{
+		{LogFlagsConfig{Since: "1h"}, []string{"applied migration", "slow migration"}},
+		{LogFlagsConfig{Until: "1h"}, []string{"migration failed"}},
+		{LogFlagsConfig{Version: 2}, []string{"applied migration", "slow migration"}},
+		{LogFlagsConfig{Level: "warn"}, []string{"migration failed", "slow migration"}},
+		{LogFlagsConfig{Level: "info", Tail: 1}, []string{"slow migration"}},
+	}
+
+	for _, f := range filters {
+		out = writeLogs(f.flags)
+
+		if strings.Count(out, "\n") != len(f.msgs) {
+			t.Errorf("should have %d entries for filter %+v; got %s\n", len(f.msgs), f.flags, out)
+			continue
+		}
+
+		for _, msg := range f.msgs {
+			if !strings.Contains(out, msg) {
+				t.Errorf("should have entry %s for filter %+v; got %s\n", msg, f.flags, out)
+			}
+		}
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that new entries are displayed when following
+	var buf bytes.Buffer
+
+	defer func(interval time.Duration) {
+		logsFollowInterval = interval
+	}(logsFollowInterval)
+
+	logsFollowInterval = time.Millisecond * 10
+	stop := make(chan struct{})
+	done := make(chan error)
+
+	c := &CDBM{LogFlags: LogFlagsConfig{LogFile: path, Tail: 1, Follow: true}}
+
+	go func() {
+		done <- c.writeLogs(&buf, stop)
+	}()
+
+	time.Sleep(time.Millisecond * 50)
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	file.WriteString("time=" + time.Now().UTC().Format(time.RFC3339Nano) + " level=info msg=followed\n")
+	file.Close()
+
+	time.Sleep(time.Millisecond * 50)
+	close(stop)
+
+	if err = <-done; err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if out = buf.String(); strings.Count(out, "\n") != 2 || !strings.Contains(out, "msg=followed") {
+		t.Errorf("should have displayed last entry and followed entry; got %s\n", out)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating invalid filter
+	c = &CDBM{LogFlags: LogFlagsConfig{LogFile: path, Since: "yesterday"}}
+
+	if err = c.writeLogs(&buf, nil); err == nil {
+		t.Errorf("should have error for invalid --since")
+	}
+}
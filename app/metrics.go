@@ -0,0 +1,201 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// metricsNamespace is namespace of every metric
+	metricsNamespace = "cdbm"
+
+	// metricsSubsystem is subsystem of every metric
+	metricsSubsystem = "migration"
+)
+
+// Metrics are prometheus metrics of migrations ran by CDBM#Migrate
+type Metrics struct {
+	// Runs counts calls to CDBM#Migrate by result ie. success | failure
+	Runs *prometheus.CounterVec
+
+	// Steps counts versions applied by direction and kind ie. file | custom
+	Steps *prometheus.CounterVec
+
+	// Failures counts versions that failed to apply by direction and kind
+	Failures *prometheus.CounterVec
+
+	// StepDuration is duration of applying versions by direction and kind
+	StepDuration *prometheus.HistogramVec
+
+	// Version is current version of schema_migrations table by database
+	Version *prometheus.GaugeVec
+
+	// Dirty is 1 if schema_migrations table is dirty, else 0 by database
+	Dirty *prometheus.GaugeVec
+
+	// LastRun is unix time of last call to CDBM#Migrate by database
+	LastRun *prometheus.GaugeVec
+
+	// gatherer is used to write metrics to textfile and is set if registerer
+	// given to NewMetrics is also a prometheus.Gatherer
+	gatherer prometheus.Gatherer
+}
+
+// NewMetrics returns *Metrics with every metric registered to given registerer
+//
+// If registerer is nil, metrics are registered to a new prometheus.Registry
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.NewRegistry()
+	}
+
+	opts := func(name, help string) prometheus.Opts {
+		return prometheus.Opts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      name,
+			Help:      help,
+		}
+	}
+
+	m := &Metrics{
+		Runs: prometheus.NewCounterVec(
+			prometheus.CounterOpts(opts("runs_total", "Number of migration runs by result.")),
+			[]string{"result"},
+		),
+		Steps: prometheus.NewCounterVec(
+			prometheus.CounterOpts(opts("steps_total", "Number of migration versions applied by direction and kind.")),
+			[]string{"direction", "kind"},
+		),
+		Failures: prometheus.NewCounterVec(
+			prometheus.CounterOpts(opts("failures_total", "Number of migration versions that failed by direction and kind.")),
+			[]string{"direction", "kind"},
+		),
+		StepDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Subsystem: metricsSubsystem,
+				Name:      "step_duration_seconds",
+				Help:      "Duration of applying migration versions by direction and kind.",
+				Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300, 900, 3600},
+			},
+			[]string{"direction", "kind"},
+		),
+		Version: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts(opts("version", "Current version of schema_migrations table.")),
+			[]string{"database"},
+		),
+		Dirty: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts(opts("dirty", "Whether schema_migrations table is dirty.")),
+			[]string{"database"},
+		),
+		LastRun: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts(opts("last_run_timestamp_seconds", "Unix time of last migration run.")),
+			[]string{"database"},
+		),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.Runs,
+		m.Steps,
+		m.Failures,
+		m.StepDuration,
+		m.Version,
+		m.Dirty,
+		m.LastRun,
+	} {
+		if err := registerer.Register(c); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	m.gatherer, _ = registerer.(prometheus.Gatherer)
+	return m, nil
+}
+
+// WriteTextfile writes every metric of registry given to NewMetrics to given
+// path in format read by node_exporter textfile collector
+func (m *Metrics) WriteTextfile(path string) error {
+	if m.gatherer == nil {
+		return fmt.Errorf("metrics registerer is not a prometheus.Gatherer so metrics can't be written to '%s'", path)
+	}
+
+	return errors.WithStack(prometheus.WriteToTextfile(path, m.gatherer))
+}
+
+// initMetrics sets CDBM#Metrics, if not set, when MigrateFlagsConfig#MetricsFile
+// is set so metrics can be written to it once migration is done
+func (cdbm *CDBM) initMetrics() error {
+	var err error
+
+	if cdbm.Metrics != nil || cdbm.MigrateFlags.MetricsFile == "" {
+		return nil
+	}
+
+	cdbm.Metrics, err = NewMetrics(nil)
+	return err
+}
+
+// observeStep records applying version of given kind and direction that
+// started at given time and failed if given error is not nil
+func (cdbm *CDBM) observeStep(kind string, direction cdbmutil.MigrationsType, start time.Time, err error) {
+	if cdbm.Metrics == nil {
+		return
+	}
+
+	// Label values are lower case ie. "up" as is convention of prometheus
+	dir := strings.ToLower(string(direction))
+
+	if err != nil {
+		cdbm.Metrics.Failures.WithLabelValues(dir, kind).Inc()
+		return
+	}
+
+	cdbm.Metrics.Steps.WithLabelValues(dir, kind).Inc()
+	cdbm.Metrics.StepDuration.WithLabelValues(dir, kind).Observe(time.Since(start).Seconds())
+}
+
+// recordRun records result of migration run along with current version and
+// dirty flag of schema_migrations table and writes metrics to
+// MigrateFlagsConfig#MetricsFile if set
+func (cdbm *CDBM) recordRun(runErr error) error {
+	if cdbm.Metrics == nil {
+		return nil
+	}
+
+	result := "success"
+
+	if runErr != nil {
+		result = "failure"
+	}
+
+	database := cdbm.currentDBSettings.DBName
+	cdbm.Metrics.Runs.WithLabelValues(result).Inc()
+	cdbm.Metrics.LastRun.WithLabelValues(database).SetToCurrentTime()
+
+	if cdbm.DB != nil {
+		if sm, err := cdbm.getSchemaMigration(); err != nil {
+			cdbm.logger().Warn("can't query schema_migrations for metrics", "error", err)
+		} else {
+			dirty := 0.0
+
+			if sm.Dirty {
+				dirty = 1
+			}
+
+			cdbm.Metrics.Version.WithLabelValues(database).Set(float64(sm.StartingVersion))
+			cdbm.Metrics.Dirty.WithLabelValues(database).Set(dirty)
+		}
+	}
+
+	if cdbm.MigrateFlags.MetricsFile == "" {
+		return nil
+	}
+
+	return cdbm.Metrics.WriteTextfile(cdbm.MigrateFlags.MetricsFile)
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	var err error
+
+	dir, err := ioutil.TempDir("", "cdbm-metrics")
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	// Validating that metrics can't be registered twice to same registerer
+	reg := prometheus.NewRegistry()
+
+	if _, err = NewMetrics(reg); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+	if _, err = NewMetrics(reg); err == nil {
+		t.Errorf("should have error")
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that metrics are not set without metrics file
+	c := &CDBM{}
+
+	if err = c.initMetrics(); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+	if c.Metrics != nil {
+		t.Errorf("should not have set metrics")
+	}
+
+	c.observeStep(migrationKindFile, cdbmutil.MigrateTypeUp, time.Now(), nil)
+
+	if err = c.recordRun(nil); err != nil {
+		t.Errorf("should not have error; got %+v", err)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that steps, failures and runs are recorded and written to
+	// metrics file along with version and dirty flag of schema_migrations
+	db, mockDB, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	path := filepath.Join(dir, "cdbm.prom")
+	c = &CDBM{
+		DB: sqlx.NewDb(db, webutil.Postgres),
+		DBProtocolCfg: cdbmutil.DBProtocolConfig{
+			MigrationTableSearch: func(db webutil.DBInterface) error {
+				return nil
+			},
+		},
+		MigrateFlags: MigrateFlagsConfig{
+			MetricsFile: path,
+		},
+	}
+
+	if err = c.initMetrics(); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+	if c.Metrics == nil {
+		t.Fatalf("should have set metrics")
+	}
+
+	c.observeStep(migrationKindFile, cdbmutil.MigrateTypeUp, time.Now(), nil)
+	c.observeStep(migrationKindFile, cdbmutil.MigrateTypeUp, time.Now(), nil)
+	c.observeStep(migrationKindCustom, cdbmutil.MigrateTypeUp, time.Now(), errors.New("error"))
+
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"version", "dirty", "dirty_state", "is_custom_migration"}).
+			AddRow(3, true, nil, true),
+	)
+
+	if err = c.recordRun(errors.New("error")); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if v := testutil.ToFloat64(c.Metrics.Steps.WithLabelValues("up", migrationKindFile)); v != 2 {
+		t.Errorf("should have 2 file steps; got %v", v)
+	}
+	if v := testutil.ToFloat64(c.Metrics.Failures.WithLabelValues("up", migrationKindCustom)); v != 1 {
+		t.Errorf("should have 1 custom failure; got %v", v)
+	}
+	if v := testutil.ToFloat64(c.Metrics.Runs.WithLabelValues("failure")); v != 1 {
+		t.Errorf("should have 1 failed run; got %v", v)
+	}
+	if v := testutil.ToFloat64(c.Metrics.Version.WithLabelValues("")); v != 3 {
+		t.Errorf("should have version 3; got %v", v)
+	}
+	if v := testutil.ToFloat64(c.Metrics.Dirty.WithLabelValues("")); v != 1 {
+		t.Errorf("should be dirty; got %v", v)
+	}
+
+	b, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	for _, line := range []string{
+		`cdbm_migration_steps_total{direction="up",kind="file"} 2`,
+		`cdbm_migration_step_duration_seconds_count{direction="up",kind="file"} 2`,
+		`cdbm_migration_runs_total{result="failure"} 1`,
+		`cdbm_migration_version{database=""} 3`,
+	} {
+		if !strings.Contains(string(b), line) {
+			t.Errorf("metrics file should contain %q; got %s", line, string(b))
+		}
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf("should not have error; got %+v", err)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that metrics can't be written with registerer that can't gather
+	m, err := NewMetrics(prometheus.WrapRegistererWithPrefix("app_", prometheus.NewRegistry()))
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+	if err = m.WriteTextfile(path); err == nil {
+		t.Errorf("should have error")
+	}
+}
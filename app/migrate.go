@@ -1,12 +1,11 @@
 package app
 
 import (
-	"bufio"
+	"context"
 	"database/sql"
 	"fmt"
 	"io/ioutil"
-	"os"
-	"path"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,9 +13,17 @@ import (
 
 	"github.com/TravisS25/cdbm/cdbmutil"
 	"github.com/TravisS25/webutil/webutil"
-	"github.com/TravisS25/webutil/webutilcfg"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// migrationKindFile is kind field of log entries of file migrations
+	migrationKindFile = "file"
+
+	// migrationKindCustom is kind field of log entries of custom migrations
+	migrationKindCustom = "custom"
 )
 
 // MigrateFlagsConfig is flag config struct for migrate command set by command line
@@ -62,6 +69,52 @@ type MigrateFlagsConfig struct {
 
 	// MigrationsDir is directory where migration files are stored whether locally or remotely
 	MigrationsDir string `yaml:"migrations_dir" mapstructure:"migrations_dir"`
+
+	// Steps migrates database relative to its current version instead of to an
+	// absolute version
+	//
+	// A positive value will migrate up that many versions and a negative value
+	// will migrate down that many versions based on the sorted list of both file
+	// and custom migrations so "-1" will always undo the last applied migration
+	//
+	// If set, TargetVersion is ignored
+	Steps int `yaml:"steps" mapstructure:"steps"`
+
+	// AllowOutOfOrder allows versions lower than the current version that have never
+	// been applied, usually from a long lived branch that merged after a higher version,
+	// to be applied before migrating
+	//
+	// If not set and unapplied lower versions are found, migration will return error
+	AllowOutOfOrder bool `yaml:"allow_out_of_order" mapstructure:"allow_out_of_order"`
+
+	// SkipTags will skip any migration that has one of the given tags and record it
+	// in schema_migrations_skipped table so it can be applied later
+	//
+	// Custom migrations get tags from CustomMigration#Tags and file migrations get
+	// tags from a header comment in up file ie. "-- cdbm:tags data,backfill"
+	SkipTags []string `yaml:"skip_tags" mapstructure:"skip_tags"`
+
+	// OnlyTags will only apply previously skipped migrations that have one of the
+	// given tags and nothing else
+	OnlyTags []string `yaml:"only_tags" mapstructure:"only_tags"`
+
+	// Verbose logs every statement of custom migrations along with their
+	// duration and rows affected, and displays them to stdout
+	//
+	// File migrations are only logged per file as golang-migrate executes
+	// whole file as one statement and only reports when file is done
+	Verbose bool `yaml:"verbose" mapstructure:"verbose"`
+
+	// MetricsFile is path metrics of migration are written to once done in
+	// format read by node_exporter textfile collector ie. cdbm.prom
+	MetricsFile string `yaml:"metrics_file" mapstructure:"metrics_file"`
+
+	// SnapshotFile is path normalized schema of database is written to once
+	// migration is successful so schema changes show up in code review
+	//
+	// Snapshot is written as json if path ends with ".json" ie. schema.json,
+	// else as sql ie. schema.sql
+	SnapshotFile string `yaml:"snapshot_file" mapstructure:"snapshot_file"`
 }
 
 // migrationApplyConfig is config struct to apply migrations and version
@@ -71,6 +124,35 @@ type migrationApplyConfig struct {
 
 	// CustomMigration is custom migrations to apply if set
 	CustomMigration cdbmutil.CustomMigration
+
+	// UpFile is path of up migration file for version if found in migrations directory
+	UpFile string
+
+	// Tags are tags of custom migration or tags found in header of up file
+	Tags []string
+
+	// Baseline is whether up file is baseline of squashed migrations
+	Baseline bool
+}
+
+// isCustomMigration determines whether config is a custom migration
+func (m migrationApplyConfig) isCustomMigration() bool {
+	return m.CustomMigration.Up != nil || m.CustomMigration.Down != nil
+}
+
+// kind returns migrationKindCustom if config is a custom migration, else migrationKindFile
+func (m migrationApplyConfig) kind() string {
+	if m.isCustomMigration() {
+		return migrationKindCustom
+	}
+
+	return migrationKindFile
+}
+
+// migrationLogger returns CDBM#Logger with version, kind and direction fields
+// of migration
+func (cdbm *CDBM) migrationLogger(version int, kind string, direction cdbmutil.MigrationsType) Logger {
+	return cdbm.logger().With("version", version, "kind", kind, "direction", direction)
 }
 
 // migrateState is struct used to keep track of certain states as migration is being run
@@ -78,15 +160,38 @@ type migrationApplyConfig struct {
 // This will be used in the CDBM struct and all properties of this struct should be
 // set by the CDBM#Migrate function
 type migrateState struct {
-	// LogWriter will write migrate errors to file system
-	LogWriter func(error)
-
 	// InsertQuery is query to insert info into schema_migrations table
 	InsertQuery string
 
 	// UpdateQuery is query to update info in schema_migrations table
 	UpdateQuery string
 
+	// AppliedInsertQuery is query to insert version into schema_migrations_applied table
+	AppliedInsertQuery string
+
+	// AppliedDeleteQuery is query to delete versions from schema_migrations_applied table
+	AppliedDeleteQuery string
+
+	// TrackApplied determines whether versions should be recorded in
+	// schema_migrations_applied table as they are migrated
+	TrackApplied bool
+
+	// AppliedVersions is every version recorded in schema_migrations_applied table
+	AppliedVersions map[int]bool
+
+	// SkippedInsertQuery is query to insert version into schema_migrations_skipped table
+	SkippedInsertQuery string
+
+	// SkippedDeleteQuery is query to delete version from schema_migrations_skipped table
+	SkippedDeleteQuery string
+
+	// SkippedDeleteAboveQuery is query to delete versions above given version from
+	// schema_migrations_skipped table
+	SkippedDeleteAboveQuery string
+
+	// SkippedVersions is every version recorded in schema_migrations_skipped table
+	SkippedVersions map[int]bool
+
 	// TargetVersion is version passed by --target-version flag
 	TargetVersion int
 
@@ -103,14 +208,69 @@ type migrateState struct {
 	SchemaMigration schemaMigration
 
 	// Migrate is migrate.Migrate instance to migrate database
+	//
+	// Its database driver pins connection of CDBM#DB that is only released by
+	// closing it, which closes CDBM#DB as well, so it's reused by every
+	// migration of same migrations source and database
 	Migrate *migrate.Migrate
+
+	// migrateSource is migrations source and migrateDB is database that
+	// Migrate was created for
+	migrateSource string
+	migrateDB     *sql.DB
+
+	// ctx is context of current trace span of migration
+	ctx context.Context
 }
 
 // Migrate migrates database based on given settings
+//
+// If CDBM#Metrics is set, or MigrateFlagsConfig#MetricsFile which will set it,
+// result of migration is recorded and metrics are written to metrics file if set
+//
+// If CDBM#TracerProvider is set, a span is recorded for migration along with
+// a span nested under it for every version applied
+//
+// If MigrateFlagsConfig#SnapshotFile is set, schema snapshot is written to it
+// once migration is successful
 func (cdbm *CDBM) Migrate(
 	getMigFunc cdbmutil.GetMigrationFunc,
 	fMigFunc cdbmutil.FileMigrationFunc,
 	cMigrations map[int]cdbmutil.CustomMigration,
+) error {
+	if err := cdbm.initMetrics(); err != nil {
+		return err
+	}
+
+	end := cdbm.startMigrateSpan()
+	err := cdbm.migrate(getMigFunc, fMigFunc, cMigrations)
+	end(err)
+
+	if metricsErr := cdbm.recordRun(err); metricsErr != nil {
+		if err != nil {
+			cdbm.logger().Error("can't record metrics", "error", metricsErr)
+			return err
+		}
+
+		return metricsErr
+	}
+
+	if err != nil || cdbm.MigrateFlags.SnapshotFile == "" {
+		return err
+	}
+
+	if err = cdbm.WriteSnapshot(cdbm.MigrateFlags.SnapshotFile); err != nil {
+		return fmt.Errorf("migrated database but can't write schema snapshot: %v", err)
+	}
+
+	return nil
+}
+
+// migrate migrates database based on given settings
+func (cdbm *CDBM) migrate(
+	getMigFunc cdbmutil.GetMigrationFunc,
+	fMigFunc cdbmutil.FileMigrationFunc,
+	cMigrations map[int]cdbmutil.CustomMigration,
 ) error {
 	var err error
 
@@ -122,24 +282,20 @@ func (cdbm *CDBM) Migrate(
 		return err
 	}
 
-	cdbm.migrateCfg.FileMigration = fMigFunc
-	cdbm.migrateCfg.CustomMigrations = cMigrations
-
-	logFile, err := cdbm.createLogsDirectory()
-
-	if err != nil {
+	if err = cdbm.applySchemaAppliedQueries(); err != nil {
 		return err
 	}
 
-	if logFile != nil {
-		defer logFile.Close()
+	if err = cdbm.applySchemaSkippedQueries(); err != nil {
+		return err
+	}
 
-		logWriter := bufio.NewWriter(logFile)
-		defer logWriter.Flush()
+	cdbm.migrateCfg.FileMigration = fMigFunc
+	cdbm.migrateCfg.CustomMigrations = cMigrations
 
-		cdbm.migrateCfg.LogWriter = func(innerErr error) {
-			logWriter.WriteString(time.Now().UTC().Format(webutilcfg.FormDateTimeLayout) + ": " + innerErr.Error() + "\n")
-		}
+	// Log file may have been set after CDBM was initiated
+	if err = cdbm.initLogger(); err != nil {
+		return err
 	}
 
 	// Query current migration status and set to CDBM#migrateCfg#SchemaMigration
@@ -153,12 +309,29 @@ func (cdbm *CDBM) Migrate(
 		return err
 	}
 
-	if cdbm.migrateCfg.Migrate, err = getMigFunc(
-		string(cdbm.MigrateFlags.MigrationsProtocol)+cdbm.MigrateFlags.MigrationsDir,
-		cdbm.DB.DB,
-		cdbm.DBProtocolCfg,
-	); err != nil {
-		return errors.WithStack(err)
+	// Database that is behind baseline of squashed migrations would have
+	// baseline applied on top of partial schema so it must be brought up to
+	// baseline version with archived migrations first
+	if err = cdbm.checkBaseline(migrationApplyCfgs); err != nil {
+		return err
+	}
+
+	if cdbm.migrateCfg.AppliedVersions, err = cdbm.getAppliedMigrations(migrationApplyCfgs); err != nil {
+		return err
+	}
+
+	if cdbm.migrateCfg.SkippedVersions, err = cdbm.getSkippedMigrations(); err != nil {
+		return err
+	}
+
+	cdbm.migrateCfg.TrackApplied = true
+
+	if err = cdbm.initMigrate(getMigFunc); err != nil {
+		return err
+	}
+
+	if cdbm.MigrateFlags.Verbose {
+		cdbm.migrateCfg.Migrate.Log = &migrateLogger{log: cdbm.logger()}
 	}
 
 	// If user is targeting specific version, make sure it exists
@@ -167,6 +340,10 @@ func (cdbm *CDBM) Migrate(
 		return err
 	}
 
+	if err = cdbm.checkBaselineTarget(migrationApplyCfgs); err != nil {
+		return err
+	}
+
 	// If schema_migrations is currently dirty, check if user sent --reset-dirty-flag flag
 	// and if they did, reset the dirty flag in the database
 	//
@@ -175,6 +352,31 @@ func (cdbm *CDBM) Migrate(
 		return err
 	}
 
+	// If there are versions lower than starting version that were never applied, only
+	// apply them if user sent --allow-out-of-order flag as otherwise they would be
+	// skipped forever
+	//
+	// Else return error so they are not silently skipped
+	if unapplied := cdbm.getUnappliedMigrations(migrationApplyCfgs); len(unapplied) > 0 {
+		if !cdbm.MigrateFlags.AllowOutOfOrder {
+			return fmt.Errorf(
+				"found unapplied versions %v lower than current version %d.  Set --allow-out-of-order to apply them",
+				unappliedVersions(unapplied),
+				cdbm.migrateCfg.SchemaMigration.StartingVersion,
+			)
+		}
+
+		if err = cdbm.applyOutOfOrderMigrations(unapplied); err != nil {
+			return err
+		}
+	}
+
+	// If user sent --only-tags flag, only apply previously skipped versions
+	// with given tags and don't migrate anything else
+	if len(cdbm.MigrateFlags.OnlyTags) > 0 {
+		return cdbm.applyOnlyTags(migrationApplyCfgs)
+	}
+
 	// fmt.Printf("dirty: %v\n", cdbm.migrateCfg.SchemaMigration.SchemaCfg.Dirty)
 	// fmt.Printf("migrate type: %v\n", cdbm.migrateCfg.MigrateType)
 	// fmt.Printf("migrate if dirty: %v\n", cdbm.MigrateFlags.MigrateDownIfDirty)
@@ -189,16 +391,56 @@ func (cdbm *CDBM) Migrate(
 			cdbm.migrateCfg.MigrateType = cdbmutil.MigrateTypeDown
 		}
 
+		cdbm.logger().Info(
+			"migrating database",
+			"from", cdbm.migrateCfg.SchemaMigration.StartingVersion,
+			"to", cdbm.migrateCfg.TargetVersion,
+			"direction", cdbm.migrateCfg.MigrateType,
+			"host", cdbm.currentDBSettings.Host,
+		)
+
+		start := time.Now()
+
 		if err = cdbm.runMigrationConfigs(migrationApplyCfgs); err != nil {
 			return err
 		}
+
+		cdbm.logger().Info(
+			"migrated database",
+			"version", cdbm.migrateCfg.TargetVersion,
+			"duration", time.Since(start),
+		)
 	} else {
+		cdbm.logger().Debug("no migrations to apply", "version", cdbm.migrateCfg.TargetVersion)
 		fmt.Printf("No Change\n")
 	}
 
 	return nil
 }
 
+// initMigrate sets CDBM#migrateCfg#Migrate with given func unless it was
+// already set for same migrations source and database by previous migration
+func (cdbm *CDBM) initMigrate(getMigFunc cdbmutil.GetMigrationFunc) error {
+	var err error
+
+	source := string(cdbm.MigrateFlags.MigrationsProtocol) + cdbm.MigrateFlags.MigrationsDir
+
+	if cdbm.migrateCfg.Migrate != nil &&
+		cdbm.migrateCfg.migrateSource == source &&
+		cdbm.migrateCfg.migrateDB == cdbm.DB.DB {
+		return nil
+	}
+
+	if cdbm.migrateCfg.Migrate, err = getMigFunc(source, cdbm.DB.DB, cdbm.DBProtocolCfg); err != nil {
+		cdbm.migrateCfg.Migrate = nil
+		return errors.WithStack(err)
+	}
+
+	cdbm.migrateCfg.migrateSource = source
+	cdbm.migrateCfg.migrateDB = cdbm.DB.DB
+	return nil
+}
+
 // checkMigrationsProtocol makes sure user sets --db-protocol flag as we need
 // this in order to apply other settings
 func (cdbm *CDBM) checkMigrationsProtocol() error {
@@ -281,23 +523,21 @@ func (cdbm *CDBM) applySchemaMigrationsQueries() error {
 }
 
 // createLogsDirectory creates logs directory where errors during migration
-// will be written to
-func (cdbm *CDBM) createLogsDirectory() (*os.File, error) {
-	if _, err := os.Stat(cdbm.LogFlags.LogFile); errors.Is(err, os.ErrNotExist) {
-		if cdbm.LogFlags.LogFile != "" {
-			baseDir, fileName := path.Split(cdbm.LogFlags.LogFile)
-
-			if err = os.MkdirAll(baseDir, os.ModePerm); err != nil {
-				return nil, errors.WithStack(err)
-			}
-
-			return os.Create(baseDir + fileName)
-		} else {
-			return nil, nil
-		}
+// will be written to and opens log file for append
+//
+// Log file is rotated based on LogFlagsConfig#LogMaxSize, LogFlagsConfig#LogMaxAge
+// and LogFlagsConfig#LogMaxBackups
+func (cdbm *CDBM) createLogsDirectory() (*rotatingFile, error) {
+	if cdbm.LogFlags.LogFile == "" {
+		return nil, nil
 	}
 
-	return os.Open(cdbm.LogFlags.LogFile)
+	return openRotatingFile(
+		cdbm.LogFlags.LogFile,
+		int64(cdbm.LogFlags.LogMaxSize)*megabyte,
+		cdbm.LogFlags.LogMaxAge,
+		cdbm.LogFlags.LogMaxBackups,
+	)
 }
 
 // verifyFilesAndMigrations loops through given migration files and
@@ -313,6 +553,8 @@ func (cdbm *CDBM) verifyFilesAndMigrations() ([]migrationApplyConfig, error) {
 	// fileVersions is used to keep track of versions between files and custom
 	// migrations to make sure there are no duplicate versioning
 	fileVersions := make(map[int]bool)
+	upFiles := make(map[int]string)
+	fileHeaders := make(map[int]fileHeader)
 	migrationApplyCfgs := make([]migrationApplyConfig, 0)
 
 	// Loop through files and make sure they follow naming convention
@@ -357,6 +599,14 @@ func (cdbm *CDBM) verifyFilesAndMigrations() ([]migrationApplyConfig, error) {
 			return nil, errors.WithStack(cdbmutil.ErrInvalidFileName)
 		}
 
+		if bodySlice[1] == "up" {
+			upFiles[version] = filepath.Join(cdbm.MigrateFlags.MigrationsDir, file.Name())
+
+			if fileHeaders[version], err = readFileHeader(upFiles[version]); err != nil {
+				return nil, err
+			}
+		}
+
 		_, ok := fileVersions[version]
 		_, customOK := cdbm.migrateCfg.CustomMigrations[version]
 
@@ -398,6 +648,17 @@ func (cdbm *CDBM) verifyFilesAndMigrations() ([]migrationApplyConfig, error) {
 		return migrationApplyCfgs[i].Version < migrationApplyCfgs[j].Version
 	})
 
+	for i := range migrationApplyCfgs {
+		migrationApplyCfgs[i].UpFile = upFiles[migrationApplyCfgs[i].Version]
+
+		if migrationApplyCfgs[i].isCustomMigration() {
+			migrationApplyCfgs[i].Tags = migrationApplyCfgs[i].CustomMigration.Tags
+		} else {
+			migrationApplyCfgs[i].Tags = fileHeaders[migrationApplyCfgs[i].Version].Tags
+			migrationApplyCfgs[i].Baseline = fileHeaders[migrationApplyCfgs[i].Version].Baseline
+		}
+	}
+
 	return migrationApplyCfgs, nil
 }
 
@@ -459,6 +720,10 @@ func (cdbm *CDBM) getSchemaMigration() (schemaMigration, error) {
 // applyTargetVersion sets given target version and will return error if
 // version doesn't exist
 func (cdbm *CDBM) applyTargetVersion(cfgs []migrationApplyConfig) error {
+	if cdbm.MigrateFlags.Steps != 0 {
+		return cdbm.applyStepsVersion(cfgs)
+	}
+
 	if cdbm.MigrateFlags.TargetVersion > -1 {
 		if cdbm.MigrateFlags.TargetVersion > cfgs[len(cfgs)-1].Version {
 			return errors.WithStack(fmt.Errorf("--target-version does not exist"))
@@ -471,6 +736,54 @@ func (cdbm *CDBM) applyTargetVersion(cfgs []migrationApplyConfig) error {
 	return nil
 }
 
+// applyStepsVersion sets target version relative to the starting version based
+// on MigrateFlagsConfig#Steps and will return error if the number of steps goes
+// past the first or last migration
+func (cdbm *CDBM) applyStepsVersion(cfgs []migrationApplyConfig) error {
+	// applied is the number of migrations currently applied to database
+	applied := 0
+
+	if !cdbm.migrateCfg.SchemaMigration.SchemaCfg.NoRows {
+		for _, cfg := range cfgs {
+			if cfg.Version > cdbm.migrateCfg.SchemaMigration.StartingVersion {
+				break
+			}
+
+			applied++
+		}
+	}
+
+	position := applied + cdbm.MigrateFlags.Steps
+
+	if position > len(cfgs) {
+		return errors.WithStack(
+			fmt.Errorf(
+				"can't migrate up %d step(s); only %d migration(s) left to apply",
+				cdbm.MigrateFlags.Steps,
+				len(cfgs)-applied,
+			),
+		)
+	}
+	if position < 0 {
+		return errors.WithStack(
+			fmt.Errorf(
+				"can't migrate down %d step(s); only %d migration(s) applied",
+				-cdbm.MigrateFlags.Steps,
+				applied,
+			),
+		)
+	}
+
+	// If position is 0, then user wants to undo every migration
+	if position == 0 {
+		cdbm.migrateCfg.TargetVersion = 0
+	} else {
+		cdbm.migrateCfg.TargetVersion = cfgs[position-1].Version
+	}
+
+	return nil
+}
+
 // resetDirtyFlag resets dirty flag if set and will return error if database
 // has dirty flag and --reset-dirty-flag is not set
 func (cdbm *CDBM) resetDirtyFlag() error {
@@ -507,15 +820,20 @@ func (cdbm *CDBM) migrationRollbackFail(version int) error {
 	var err error
 
 	for version > cdbm.migrateCfg.SchemaMigration.StartingVersion {
+		// Versions skipped by --skip-tags were never applied so there is
+		// nothing to roll back
+		if cdbm.migrateCfg.SkippedVersions[version] {
+			version--
+			continue
+		}
+
 		// Check if current version is apart of a custom migration
 		//
 		// Else run file down migrations
 		if migration, ok := cdbm.migrateCfg.CustomMigrations[version]; ok {
 			if migration.Down != nil {
-				if err = migration.Down(cdbm.DB); err != nil {
-					if cdbm.migrateCfg.LogWriter != nil {
-						cdbm.migrateCfg.LogWriter(err)
-					}
+				if err = cdbm.runCustomMigration(migration, migration.Down, cdbm.migrationLogger(version, migrationKindCustom, cdbmutil.MigrateTypeDown)); err != nil {
+					cdbm.migrationLogger(version, migrationKindCustom, cdbmutil.MigrateTypeDown).Error("custom rollback migration failed", "error", err)
 
 					var query string
 
@@ -532,9 +850,7 @@ func (cdbm *CDBM) migrationRollbackFail(version int) error {
 						cdbmutil.MigrateTypeDown,
 						true,
 					); err != nil {
-						if cdbm.migrateCfg.LogWriter != nil {
-							cdbm.migrateCfg.LogWriter(err)
-						}
+						cdbm.migrationLogger(version, migrationKindCustom, cdbmutil.MigrateTypeDown).Error("can't update schema_migrations", "error", err)
 					}
 
 					return fmt.Errorf(
@@ -549,9 +865,7 @@ func (cdbm *CDBM) migrationRollbackFail(version int) error {
 				version,
 				cdbmutil.MigrateTypeDown,
 			); err != nil {
-				if cdbm.migrateCfg.LogWriter != nil {
-					cdbm.migrateCfg.LogWriter(err)
-				}
+				cdbm.migrationLogger(version, migrationKindFile, cdbmutil.MigrateTypeDown).Error("file rollback migration failed", "error", err)
 
 				if _, err = cdbm.DB.Exec(
 					cdbm.migrateCfg.UpdateQuery,
@@ -560,9 +874,7 @@ func (cdbm *CDBM) migrationRollbackFail(version int) error {
 					cdbmutil.MigrateTypeDown,
 					false,
 				); err != nil {
-					if cdbm.migrateCfg.LogWriter != nil {
-						cdbm.migrateCfg.LogWriter(err)
-					}
+					cdbm.migrationLogger(version, migrationKindFile, cdbmutil.MigrateTypeDown).Error("can't update schema_migrations", "error", err)
 				}
 
 				return fmt.Errorf(
@@ -575,20 +887,40 @@ func (cdbm *CDBM) migrationRollbackFail(version int) error {
 		version--
 	}
 
-	return nil
+	return cdbm.removeAppliedAbove(cdbm.migrateCfg.SchemaMigration.StartingVersion)
 }
 
-// applyCustomMigration applies custom migration to database
+// applyCustomMigration applies custom migration to database within span of its version
 func (cdbm *CDBM) applyCustomMigration(applyCfg migrationApplyConfig) error {
+	end := cdbm.startVersionSpan(applyCfg.Version, migrationKindCustom)
+	err := cdbm.applyCustomVersion(applyCfg)
+	end(err)
+	return err
+}
+
+// applyCustomVersion applies custom migration to database
+func (cdbm *CDBM) applyCustomVersion(applyCfg migrationApplyConfig) error {
 	var err, innerErr error
 
+	start := time.Now()
+
+	// If custom migration is idempotent, there's no need to reset dirty state
+	// with down migration as up migration can simply be applied again
+	if cdbm.migrateCfg.SchemaMigration.SchemaCfg.Dirty &&
+		cdbm.migrateCfg.MigrateType == cdbmutil.MigrateTypeUp &&
+		applyCfg.CustomMigration.Idempotent {
+		cdbm.migrateCfg.SchemaMigration.SchemaCfg.Dirty = false
+	}
+
 	if cdbm.migrateCfg.SchemaMigration.SchemaCfg.Dirty &&
 		cdbm.migrateCfg.MigrateType == cdbmutil.MigrateTypeUp &&
 		applyCfg.CustomMigration.Down != nil {
-		if err = applyCfg.CustomMigration.Down(cdbm.DB); err != nil {
-			if cdbm.migrateCfg.LogWriter != nil {
-				cdbm.migrateCfg.LogWriter(err)
-			}
+		if err = cdbm.runCustomMigration(
+			applyCfg.CustomMigration,
+			applyCfg.CustomMigration.Down,
+			cdbm.migrationLogger(applyCfg.Version, migrationKindCustom, cdbmutil.MigrateTypeDown),
+		); err != nil {
+			cdbm.migrationLogger(applyCfg.Version, migrationKindCustom, cdbmutil.MigrateTypeDown).Error("custom down migration to reset dirty state failed", "error", err)
 
 			if _, err = cdbm.DB.Exec(
 				cdbm.migrateCfg.UpdateQuery,
@@ -597,9 +929,7 @@ func (cdbm *CDBM) applyCustomMigration(applyCfg migrationApplyConfig) error {
 				cdbmutil.MigrateTypeDown,
 				false,
 			); err != nil {
-				if cdbm.migrateCfg.LogWriter != nil {
-					cdbm.migrateCfg.LogWriter(err)
-				}
+				cdbm.migrationLogger(applyCfg.Version, migrationKindCustom, cdbmutil.MigrateTypeDown).Error("can't update schema_migrations", "error", err)
 			}
 
 			return errors.WithStack(
@@ -621,10 +951,13 @@ func (cdbm *CDBM) applyCustomMigration(applyCfg migrationApplyConfig) error {
 
 	// If custom migration function has error, begin process of logging and trying
 	// to rollback migration if set
-	if err = cmFunc(cdbm.DB); err != nil {
-		if cdbm.migrateCfg.LogWriter != nil {
-			cdbm.migrateCfg.LogWriter(err)
-		}
+	if err = cdbm.runCustomMigration(
+		applyCfg.CustomMigration,
+		cmFunc,
+		cdbm.migrationLogger(applyCfg.Version, migrationKindCustom, cdbm.migrateCfg.MigrateType),
+	); err != nil {
+		cdbm.migrationLogger(applyCfg.Version, migrationKindCustom, cdbm.migrateCfg.MigrateType).Error("custom migration failed", "error", err)
+		cdbm.observeStep(migrationKindCustom, cdbm.migrateCfg.MigrateType, start, err)
 
 		if cdbm.migrateCfg.MigrateType == cdbmutil.MigrateTypeUp {
 			err = fmt.Errorf("failed on custom up migration for version: '%d'.  Error: %+v", applyCfg.Version, err)
@@ -650,9 +983,7 @@ func (cdbm *CDBM) applyCustomMigration(applyCfg migrationApplyConfig) error {
 			// If error occurs during rollback, add to logger and return both
 			// migration and rollback errors
 			if innerErr = cdbm.migrationRollbackFail(applyCfg.Version); innerErr != nil {
-				if cdbm.migrateCfg.LogWriter != nil {
-					cdbm.migrateCfg.LogWriter(err)
-				}
+				cdbm.migrationLogger(applyCfg.Version, migrationKindCustom, cdbm.migrateCfg.MigrateType).Error("rollback failed", "error", innerErr)
 
 				return fmt.Errorf(err.Error() + " and " + innerErr.Error())
 			}
@@ -664,9 +995,7 @@ func (cdbm *CDBM) applyCustomMigration(applyCfg migrationApplyConfig) error {
 				"",
 				true,
 			); innerErr != nil {
-				if cdbm.migrateCfg.LogWriter != nil {
-					cdbm.migrateCfg.LogWriter(err)
-				}
+				cdbm.migrationLogger(applyCfg.Version, migrationKindCustom, cdbm.migrateCfg.MigrateType).Error("can't update schema_migrations", "error", innerErr)
 			}
 
 			return fmt.Errorf(
@@ -681,9 +1010,7 @@ func (cdbm *CDBM) applyCustomMigration(applyCfg migrationApplyConfig) error {
 				cdbm.migrateCfg.MigrateType,
 				true,
 			); innerErr != nil {
-				if cdbm.migrateCfg.LogWriter != nil {
-					cdbm.migrateCfg.LogWriter(err)
-				}
+				cdbm.migrationLogger(applyCfg.Version, migrationKindCustom, cdbm.migrateCfg.MigrateType).Error("can't update schema_migrations", "error", innerErr)
 			}
 		}
 
@@ -698,9 +1025,7 @@ func (cdbm *CDBM) applyCustomMigration(applyCfg migrationApplyConfig) error {
 			"",
 			true,
 		); err != nil {
-			if cdbm.migrateCfg.LogWriter != nil {
-				cdbm.migrateCfg.LogWriter(err)
-			}
+			cdbm.migrationLogger(applyCfg.Version, migrationKindCustom, cdbm.migrateCfg.MigrateType).Error("can't update schema_migrations", "error", err)
 
 			return errors.WithStack(err)
 		}
@@ -712,9 +1037,7 @@ func (cdbm *CDBM) applyCustomMigration(applyCfg migrationApplyConfig) error {
 			"",
 			true,
 		); err != nil {
-			if cdbm.migrateCfg.LogWriter != nil {
-				cdbm.migrateCfg.LogWriter(err)
-			}
+			cdbm.migrationLogger(applyCfg.Version, migrationKindCustom, cdbm.migrateCfg.MigrateType).Error("can't update schema_migrations", "error", err)
 
 			return errors.WithStack(err)
 		}
@@ -724,13 +1047,85 @@ func (cdbm *CDBM) applyCustomMigration(applyCfg migrationApplyConfig) error {
 	// onc migration has to occur so make NoRows = false
 	cdbm.migrateCfg.SchemaMigration.SchemaCfg.NoRows = false
 
+	cdbm.migrationLogger(applyCfg.Version, migrationKindCustom, cdbm.migrateCfg.MigrateType).Info(
+		"applied migration",
+		"duration", time.Since(start),
+	)
+	cdbm.observeStep(migrationKindCustom, cdbm.migrateCfg.MigrateType, start, nil)
 	return nil
 }
 
-// applyFileMigration applies file migration to database
+// runCustomMigration runs given function of custom migration against database
+// within a transaction and timeout if they are set for custom migration
+//
+// If MigrateFlagsConfig#Verbose is set, every statement is logged to given Logger
+func (cdbm *CDBM) runCustomMigration(cm cdbmutil.CustomMigration, cmFunc cdbmutil.CustomMigrationFunc, log Logger) error {
+	var err error
+
+	// Context carries span of version, if any, so queries and spans of
+	// custom migration are nested under it
+	ctx := cdbm.migrateContext()
+
+	if cm.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, cm.Timeout)
+		defer cancel()
+	}
+
+	// timeoutErr will return error stating timeout was reached if that
+	// is the reason function failed
+	timeoutErr := func(err error) error {
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("custom migration exceeded timeout of %s: %v", cm.Timeout, err)
+		}
+
+		return err
+	}
+
+	if !cdbm.MigrateFlags.Verbose {
+		log = nil
+	}
+
+	if !cm.Transaction {
+		mdb := newMigrationDB(ctx, cdbm.DB, cdbm.DB, log)
+		err = timeoutErr(cmFunc(mdb))
+		trace.SpanFromContext(ctx).SetAttributes(attrRowsAffected.Int64(mdb.rowsAffected))
+		return err
+	}
+
+	tx, err := cdbm.DB.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return errors.WithStack(timeoutErr(err))
+	}
+
+	mdb := newMigrationDB(ctx, cdbm.DB, tx, log)
+
+	if err = cmFunc(mdb); err != nil {
+		tx.Rollback()
+		return timeoutErr(err)
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attrRowsAffected.Int64(mdb.rowsAffected))
+
+	return errors.WithStack(timeoutErr(tx.Commit()))
+}
+
+// applyFileMigration applies file migration to database within span of its version
 func (cdbm *CDBM) applyFileMigration(version int) error {
+	end := cdbm.startVersionSpan(version, migrationKindFile)
+	err := cdbm.applyFileVersion(version)
+	end(err)
+	return err
+}
+
+// applyFileVersion applies file migration to database
+func (cdbm *CDBM) applyFileVersion(version int) error {
 	var err, innerErr error
 
+	start := time.Now()
+
 	if cdbm.migrateCfg.SchemaMigration.SchemaCfg.Dirty &&
 		cdbm.migrateCfg.MigrateType == cdbmutil.MigrateTypeUp {
 
@@ -739,9 +1134,7 @@ func (cdbm *CDBM) applyFileMigration(version int) error {
 			version,
 			cdbmutil.MigrateTypeDown,
 		); err != nil {
-			if cdbm.migrateCfg.LogWriter != nil {
-				cdbm.migrateCfg.LogWriter(err)
-			}
+			cdbm.migrationLogger(version, migrationKindFile, cdbmutil.MigrateTypeDown).Error("file down migration to reset dirty state failed", "error", err)
 
 			if _, err = cdbm.DB.Exec(
 				cdbm.migrateCfg.UpdateQuery,
@@ -750,9 +1143,7 @@ func (cdbm *CDBM) applyFileMigration(version int) error {
 				cdbmutil.MigrateTypeDown,
 				false,
 			); err != nil {
-				if cdbm.migrateCfg.LogWriter != nil {
-					cdbm.migrateCfg.LogWriter(err)
-				}
+				cdbm.migrationLogger(version, migrationKindFile, cdbmutil.MigrateTypeDown).Error("can't update schema_migrations", "error", err)
 			}
 
 			return errors.WithStack(
@@ -774,9 +1165,8 @@ func (cdbm *CDBM) applyFileMigration(version int) error {
 		version,
 		cdbm.migrateCfg.MigrateType,
 	); err != nil {
-		if cdbm.migrateCfg.LogWriter != nil {
-			cdbm.migrateCfg.LogWriter(err)
-		}
+		cdbm.migrationLogger(version, migrationKindFile, cdbm.migrateCfg.MigrateType).Error("file migration failed", "error", err)
+		cdbm.observeStep(migrationKindFile, cdbm.migrateCfg.MigrateType, start, err)
 
 		if cdbm.migrateCfg.MigrateType == cdbmutil.MigrateTypeUp {
 			err = fmt.Errorf("failed on file up migration for version: '%d'.  Error: %+v\n", version, err)
@@ -792,9 +1182,7 @@ func (cdbm *CDBM) applyFileMigration(version int) error {
 			// If error occurs during rollback, add to logger and return both
 			// migration and rollback errors
 			if innerErr = cdbm.migrationRollbackFail(version); innerErr != nil {
-				if cdbm.migrateCfg.LogWriter != nil {
-					cdbm.migrateCfg.LogWriter(err)
-				}
+				cdbm.migrationLogger(version, migrationKindFile, cdbm.migrateCfg.MigrateType).Error("rollback failed", "error", innerErr)
 
 				return fmt.Errorf(err.Error() + "\n" + innerErr.Error())
 			}
@@ -806,9 +1194,7 @@ func (cdbm *CDBM) applyFileMigration(version int) error {
 				"",
 				false,
 			); innerErr != nil {
-				if cdbm.migrateCfg.LogWriter != nil {
-					cdbm.migrateCfg.LogWriter(err)
-				}
+				cdbm.migrationLogger(version, migrationKindFile, cdbm.migrateCfg.MigrateType).Error("can't update schema_migrations", "error", innerErr)
 			}
 
 			return fmt.Errorf(
@@ -823,9 +1209,7 @@ func (cdbm *CDBM) applyFileMigration(version int) error {
 				cdbm.migrateCfg.MigrateType,
 				false,
 			); innerErr != nil {
-				if cdbm.migrateCfg.LogWriter != nil {
-					cdbm.migrateCfg.LogWriter(err)
-				}
+				cdbm.migrationLogger(version, migrationKindFile, cdbm.migrateCfg.MigrateType).Error("can't update schema_migrations", "error", innerErr)
 			}
 		}
 
@@ -839,9 +1223,7 @@ func (cdbm *CDBM) applyFileMigration(version int) error {
 				"",
 				false,
 			); err != nil {
-				if cdbm.migrateCfg.LogWriter != nil {
-					cdbm.migrateCfg.LogWriter(err)
-				}
+				cdbm.migrationLogger(version, migrationKindFile, cdbm.migrateCfg.MigrateType).Error("can't update schema_migrations", "error", err)
 
 				return errors.WithStack(err)
 			}
@@ -852,6 +1234,11 @@ func (cdbm *CDBM) applyFileMigration(version int) error {
 	// onc migration has to occur so make NoRows = false
 	cdbm.migrateCfg.SchemaMigration.SchemaCfg.NoRows = false
 
+	cdbm.migrationLogger(version, migrationKindFile, cdbm.migrateCfg.MigrateType).Info(
+		"applied migration",
+		"duration", time.Since(start),
+	)
+	cdbm.observeStep(migrationKindFile, cdbm.migrateCfg.MigrateType, start, nil)
 	return nil
 }
 
@@ -874,7 +1261,7 @@ func (cdbm *CDBM) runMigrationConfigs(cfgs []migrationApplyConfig) error {
 			}
 		}
 
-		return nil
+		return cdbm.recordApplied(cfg)
 	}
 
 	if cdbm.migrateCfg.MigrateType == cdbmutil.MigrateTypeUp {
@@ -898,6 +1285,10 @@ func (cdbm *CDBM) runMigrationConfigs(cfgs []migrationApplyConfig) error {
 						return err
 					}
 				}
+
+				if err = cdbm.recordApplied(cfg); err != nil {
+					return err
+				}
 			} else {
 				// If current apply config version is less than or equal to starting version
 				// continue loop as we are migrating up and want the next version up
@@ -911,6 +1302,16 @@ func (cdbm *CDBM) runMigrationConfigs(cfgs []migrationApplyConfig) error {
 					break
 				}
 
+				// If current apply config has tag passed by --skip-tags, move past version
+				// without applying it so it can be applied later
+				if cdbm.isSkippedByTags(cfg) {
+					if err = cdbm.skipMigration(cfg); err != nil {
+						return err
+					}
+
+					continue
+				}
+
 				if err = applyMigration(cfg); err != nil {
 					return err
 				}
@@ -918,7 +1319,9 @@ func (cdbm *CDBM) runMigrationConfigs(cfgs []migrationApplyConfig) error {
 		}
 	} else {
 		for i := len(cfgs) - 1; i >= 0; i-- {
-			if cdbm.migrateCfg.TargetVersion == 0 && i == 0 {
+			// If first version was skipped, it's moved down to like any other
+			// version and schema_migrations is cleared once loop is done
+			if cdbm.migrateCfg.TargetVersion == 0 && i == 0 && !cdbm.migrateCfg.SkippedVersions[cfgs[i].Version] {
 				if cfgs[i].CustomMigration.Up != nil || cfgs[i].CustomMigration.Down != nil {
 					if err = cdbm.applyCustomMigration(cfgs[i]); err != nil {
 						return err
@@ -926,6 +1329,10 @@ func (cdbm *CDBM) runMigrationConfigs(cfgs []migrationApplyConfig) error {
 				} else if err = cdbm.applyFileMigration(0); err != nil {
 					return err
 				}
+
+				if err = cdbm.removeAppliedAbove(0); err != nil {
+					return err
+				}
 			} else {
 				// If current apply config version is greater than or equal to starting version
 				// continue loop as we are migrating down and want the next version down
@@ -939,6 +1346,16 @@ func (cdbm *CDBM) runMigrationConfigs(cfgs []migrationApplyConfig) error {
 					break
 				}
 
+				// If version above current apply config was skipped, it was never applied
+				// so simply move version down without running its down migration
+				if cdbm.migrateCfg.SkippedVersions[cfgs[i+1].Version] {
+					if err = cdbm.unskipMigration(cfgs[i], cfgs[i+1]); err != nil {
+						return err
+					}
+
+					continue
+				}
+
 				// If CustomMigration functions are defined then we are currently on custom migration
 				if cfgs[i].CustomMigration.Up != nil || cfgs[i].CustomMigration.Down != nil {
 
@@ -964,6 +1381,22 @@ func (cdbm *CDBM) runMigrationConfigs(cfgs []migrationApplyConfig) error {
 						return err
 					}
 				}
+
+				if err = cdbm.removeAppliedAbove(cfgs[i].Version); err != nil {
+					return err
+				}
+			}
+		}
+
+		// If first version was skipped, it was never applied so simply clear
+		// schema_migrations without running its down migration
+		if cdbm.migrateCfg.TargetVersion == 0 && len(cfgs) > 0 && cdbm.migrateCfg.SkippedVersions[cfgs[0].Version] {
+			if err = cdbm.unskipFirstMigration(cfgs[0]); err != nil {
+				return err
+			}
+
+			if err = cdbm.removeAppliedAbove(0); err != nil {
+				return err
 			}
 		}
 	}
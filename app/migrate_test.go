@@ -2,12 +2,14 @@ package app
 
 import (
 	"bufio"
+	"bytes"
 	"database/sql"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
@@ -561,6 +563,98 @@ func TestApplyTargetVersion(t *testing.T) {
 	if mApp.migrateCfg.TargetVersion != 1 {
 		t.Errorf("target version should equal 1; got %d\n", mApp.migrateCfg.TargetVersion)
 	}
+
+	// --------------------------------------------------------------------------
+
+	stepCfgs := []migrationApplyConfig{
+		{
+			Version: 1,
+		},
+		{
+			Version: 2,
+		},
+		{
+			Version: 5,
+		},
+		{
+			Version: 7,
+		},
+	}
+
+	mApp = &CDBM{
+		MigrateFlags: MigrateFlagsConfig{
+			TargetVersion: 7,
+			Steps:         -1,
+		},
+		migrateCfg: migrateState{
+			SchemaMigration: schemaMigration{
+				StartingVersion: 5,
+			},
+		},
+	}
+
+	if err = mApp.applyTargetVersion(stepCfgs); err != nil {
+		t.Errorf("should not have error; got %s\n", err.Error())
+	}
+
+	if mApp.migrateCfg.TargetVersion != 2 {
+		t.Errorf("target version should equal 2; got %d\n", mApp.migrateCfg.TargetVersion)
+	}
+
+	// --------------------------------------------------------------------------
+
+	mApp.MigrateFlags.Steps = 2
+
+	if err = mApp.applyTargetVersion(stepCfgs); err == nil {
+		t.Errorf("should have error")
+	} else if err.Error() != "can't migrate up 2 step(s); only 1 migration(s) left to apply" {
+		t.Errorf("should have up steps error; got %s\n", err.Error())
+	}
+
+	// --------------------------------------------------------------------------
+
+	mApp.MigrateFlags.Steps = -3
+
+	if err = mApp.applyTargetVersion(stepCfgs); err != nil {
+		t.Errorf("should not have error; got %s\n", err.Error())
+	}
+
+	if mApp.migrateCfg.TargetVersion != 0 {
+		t.Errorf("target version should equal 0; got %d\n", mApp.migrateCfg.TargetVersion)
+	}
+
+	// --------------------------------------------------------------------------
+
+	mApp.MigrateFlags.Steps = -4
+
+	if err = mApp.applyTargetVersion(stepCfgs); err == nil {
+		t.Errorf("should have error")
+	} else if err.Error() != "can't migrate down 4 step(s); only 3 migration(s) applied" {
+		t.Errorf("should have down steps error; got %s\n", err.Error())
+	}
+
+	// --------------------------------------------------------------------------
+
+	mApp = &CDBM{
+		MigrateFlags: MigrateFlagsConfig{
+			Steps: 2,
+		},
+		migrateCfg: migrateState{
+			SchemaMigration: schemaMigration{
+				SchemaCfg: schemaConfig{
+					NoRows: true,
+				},
+			},
+		},
+	}
+
+	if err = mApp.applyTargetVersion(stepCfgs); err != nil {
+		t.Errorf("should not have error; got %s\n", err.Error())
+	}
+
+	if mApp.migrateCfg.TargetVersion != 2 {
+		t.Errorf("target version should equal 2; got %d\n", mApp.migrateCfg.TargetVersion)
+	}
 }
 
 func TestResetDirtyFlag(t *testing.T) {
@@ -705,6 +799,13 @@ func TestMigrationRollbackFail(t *testing.T) {
 	// Validating custom down migration error with having rows in schema_migrations table
 	mApp = &CDBM{
 		DB: db,
+		Logger: errorLogger(func(err error) {
+			if err == nil {
+				t.Errorf("should have error for logger")
+			} else if err.Error() != "custom down migration error" {
+				t.Errorf("should have custom down migration error; got %s\n", err.Error())
+			}
+		}),
 		MigrateFlags: MigrateFlagsConfig{
 			RollbackOnFailure: true,
 		},
@@ -712,13 +813,6 @@ func TestMigrationRollbackFail(t *testing.T) {
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
 			MigrateType: cdbmutil.MigrateTypeUp,
-			LogWriter: func(err error) {
-				if err == nil {
-					t.Errorf("should have error for logger")
-				} else if err.Error() != "custom down migration error" {
-					t.Errorf("should have custom down migration error; got %s\n", err.Error())
-				}
-			},
 			CustomMigrations: map[int]cdbmutil.CustomMigration{
 				2: {
 					Down: func(db webutil.DBInterface) error {
@@ -759,6 +853,13 @@ func TestMigrationRollbackFail(t *testing.T) {
 	// Validating custom down migration error with having no rows in schema_migrations table
 	mApp = &CDBM{
 		DB: db,
+		Logger: errorLogger(func(err error) {
+			if err == nil {
+				t.Errorf("should have error for logger")
+			} else if err.Error() != "custom down migration error" {
+				t.Errorf("should have custom down migration error; got %s\n", err.Error())
+			}
+		}),
 		MigrateFlags: MigrateFlagsConfig{
 			RollbackOnFailure: true,
 		},
@@ -766,13 +867,6 @@ func TestMigrationRollbackFail(t *testing.T) {
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
 			MigrateType: cdbmutil.MigrateTypeUp,
-			LogWriter: func(err error) {
-				if err == nil {
-					t.Errorf("should have error for logger")
-				} else if err.Error() != "custom down migration error" {
-					t.Errorf("should have custom down migration error; got %s\n", err.Error())
-				}
-			},
 			CustomMigrations: map[int]cdbmutil.CustomMigration{
 				2: {
 					Down: func(db webutil.DBInterface) error {
@@ -813,6 +907,13 @@ func TestMigrationRollbackFail(t *testing.T) {
 	// Validating file down migration error
 	mApp = &CDBM{
 		DB: db,
+		Logger: errorLogger(func(err error) {
+			if err == nil {
+				t.Errorf("should have error for logger")
+			} else if err.Error() != "file down migration error" {
+				t.Errorf("should have file down migration error; got %s\n", err.Error())
+			}
+		}),
 		MigrateFlags: MigrateFlagsConfig{
 			RollbackOnFailure: true,
 		},
@@ -820,13 +921,6 @@ func TestMigrationRollbackFail(t *testing.T) {
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
 			MigrateType: cdbmutil.MigrateTypeUp,
-			LogWriter: func(err error) {
-				if err == nil {
-					t.Errorf("should have error for logger")
-				} else if err.Error() != "file down migration error" {
-					t.Errorf("should have file down migration error; got %s\n", err.Error())
-				}
-			},
 			FileMigration: func(mig *migrate.Migrate, version int, mt cdbmutil.MigrationsType) error {
 				return fmt.Errorf("file down migration error")
 			},
@@ -863,6 +957,13 @@ func TestMigrationRollbackFail(t *testing.T) {
 	// Validating file down migration error with having rows in schema_migration table
 	mApp = &CDBM{
 		DB: db,
+		Logger: errorLogger(func(err error) {
+			if err == nil {
+				t.Errorf("should have error for logger")
+			} else if err.Error() != "file down migration error" {
+				t.Errorf("should have file down migration error; got %s\n", err.Error())
+			}
+		}),
 		MigrateFlags: MigrateFlagsConfig{
 			RollbackOnFailure: true,
 		},
@@ -870,13 +971,6 @@ func TestMigrationRollbackFail(t *testing.T) {
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
 			MigrateType: cdbmutil.MigrateTypeUp,
-			LogWriter: func(err error) {
-				if err == nil {
-					t.Errorf("should have error for logger")
-				} else if err.Error() != "file down migration error" {
-					t.Errorf("should have file down migration error; got %s\n", err.Error())
-				}
-			},
 			FileMigration: func(mig *migrate.Migrate, version int, mt cdbmutil.MigrationsType) error {
 				return fmt.Errorf("file down migration error")
 			},
@@ -909,6 +1003,13 @@ func TestMigrationRollbackFail(t *testing.T) {
 	// Should be valid with no rows
 	mApp = &CDBM{
 		DB: db,
+		Logger: errorLogger(func(err error) {
+			if err == nil {
+				t.Errorf("should have error for logger")
+			} else if err.Error() != "file down migration error" {
+				t.Errorf("should have file down migration error; got %s\n", err.Error())
+			}
+		}),
 		MigrateFlags: MigrateFlagsConfig{
 			RollbackOnFailure: true,
 		},
@@ -916,13 +1017,6 @@ func TestMigrationRollbackFail(t *testing.T) {
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
 			MigrateType: cdbmutil.MigrateTypeDown,
-			LogWriter: func(err error) {
-				if err == nil {
-					t.Errorf("should have error for logger")
-				} else if err.Error() != "file down migration error" {
-					t.Errorf("should have file down migration error; got %s\n", err.Error())
-				}
-			},
 			FileMigration: func(mig *migrate.Migrate, version int, mt cdbmutil.MigrationsType) error {
 				return nil
 			},
@@ -939,6 +1033,183 @@ func TestMigrationRollbackFail(t *testing.T) {
 	}
 }
 
+func TestMigrationRollbackFailSkipped(t *testing.T) {
+	rolledBack := make([]int, 0)
+
+	customDown := func(version int) cdbmutil.CustomMigration {
+		return cdbmutil.CustomMigration{
+			Up: func(db webutil.DBInterface) error {
+				return nil
+			},
+			Down: func(db webutil.DBInterface) error {
+				rolledBack = append(rolledBack, version)
+				return nil
+			},
+		}
+	}
+
+	mApp := &CDBM{
+		migrateCfg: migrateState{
+			MigrateType: cdbmutil.MigrateTypeUp,
+			CustomMigrations: map[int]cdbmutil.CustomMigration{
+				2: customDown(2),
+				3: customDown(3),
+				4: customDown(4),
+			},
+			SkippedVersions: map[int]bool{
+				3: true,
+			},
+			SchemaMigration: schemaMigration{
+				StartingVersion: 1,
+			},
+		},
+	}
+
+	// Validating that versions skipped by --skip-tags are not rolled back
+	if err := mApp.migrationRollbackFail(4); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if fmt.Sprint(rolledBack) != "[4 2]" {
+		t.Errorf("should have rolled back versions [4 2]; got %v", rolledBack)
+	}
+}
+
+func TestRunMigrationConfigsSkippedFirst(t *testing.T) {
+	db, mockDB, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	applied := make([]string, 0)
+	cfgs := []migrationApplyConfig{
+		{Version: 1, UpFile: "000001_backfill.up.sql", Tags: []string{"backfill"}},
+		{Version: 2, UpFile: "000002_users.up.sql"},
+	}
+
+	mApp := &CDBM{
+		DB: sqlx.NewDb(db, webutil.Postgres),
+		migrateCfg: migrateState{
+			MigrateType:   cdbmutil.MigrateTypeDown,
+			TargetVersion: 0,
+			TrackApplied:  true,
+			FileMigration: func(mig *migrate.Migrate, version int, mt cdbmutil.MigrationsType) error {
+				applied = append(applied, fmt.Sprintf("%d %s", version, mt))
+				return nil
+			},
+			AppliedVersions: map[int]bool{
+				2: true,
+			},
+			SkippedVersions: map[int]bool{
+				1: true,
+			},
+			SchemaMigration: schemaMigration{
+				StartingVersion: 2,
+			},
+		},
+	}
+
+	// Validating that migrating down to 0 doesn't run down migration of first
+	// version that was skipped and clears schema_migrations instead
+	// Version 2 moved down to version 1
+	mockDB.ExpectExec("").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mockDB.ExpectExec("").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Version 1 removed from skipped versions along with applied versions
+	mockDB.ExpectExec("").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mockDB.ExpectExec("").WithArgs(0).WillReturnResult(sqlmock.NewResult(0, 0))
+	mockDB.ExpectExec("").WithArgs(0).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err = mApp.runMigrationConfigs(cfgs); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	expected := []string{
+		fmt.Sprintf("1 %s", cdbmutil.MigrateTypeDown),
+		fmt.Sprintf("-1 %s", cdbmutil.MigrateTypeForce),
+	}
+
+	if fmt.Sprint(applied) != fmt.Sprint(expected) {
+		t.Errorf("should have applied %v; got %v", expected, applied)
+	}
+
+	if mApp.migrateCfg.SkippedVersions[1] || len(mApp.migrateCfg.AppliedVersions) != 0 {
+		t.Errorf(
+			"should have removed applied and skipped versions; got %v and %v",
+			mApp.migrateCfg.AppliedVersions,
+			mApp.migrateCfg.SkippedVersions,
+		)
+	}
+
+	if !mApp.migrateCfg.SchemaMigration.SchemaCfg.NoRows {
+		t.Errorf("should have no rows in schema_migrations")
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+}
+
+func TestInitMigrate(t *testing.T) {
+	db1, _, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	db2, _, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	calls := 0
+	getMigFunc := func(migDir string, db *sql.DB, protocolCfg cdbmutil.DBProtocolConfig) (*migrate.Migrate, error) {
+		calls++
+		return &migrate.Migrate{}, nil
+	}
+
+	c := &CDBM{
+		DB: sqlx.NewDb(db1, webutil.Postgres),
+		MigrateFlags: MigrateFlagsConfig{
+			MigrationsProtocol: cdbmutil.FileProtocol,
+			MigrationsDir:      "./migrations",
+		},
+	}
+
+	// Validating that migrate instance is reused by migrations of same
+	// source and database
+	for i := 0; i < 3; i++ {
+		if err = c.initMigrate(getMigFunc); err != nil {
+			t.Fatalf("should not have error; got %+v", err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("should have created migrate instance once; got %d", calls)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that new instance is created for different database or source
+	c.DB = sqlx.NewDb(db2, webutil.Postgres)
+
+	if err = c.initMigrate(getMigFunc); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	c.MigrateFlags.MigrationsDir = "./other"
+
+	if err = c.initMigrate(getMigFunc); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("should have created migrate instance 3 times; got %d", calls)
+	}
+}
+
 func TestApplyCustomMigration(t *testing.T) {
 	var err error
 	var mApp *CDBM
@@ -978,7 +1249,6 @@ func TestApplyCustomMigration(t *testing.T) {
 			MigrateType: cdbmutil.MigrateTypeUp,
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
-			LogWriter:   func(err error) {},
 		},
 	}
 
@@ -1014,7 +1284,6 @@ func TestApplyCustomMigration(t *testing.T) {
 			MigrateType: cdbmutil.MigrateTypeUp,
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
-			LogWriter:   func(err error) {},
 			SchemaMigration: schemaMigration{
 				SchemaCfg: schemaConfig{
 					Dirty: true,
@@ -1051,7 +1320,6 @@ func TestApplyCustomMigration(t *testing.T) {
 			MigrateType: cdbmutil.MigrateTypeUp,
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
-			LogWriter:   func(err error) {},
 			SchemaMigration: schemaMigration{
 				SchemaCfg: schemaConfig{
 					Dirty: true,
@@ -1087,7 +1355,6 @@ func TestApplyCustomMigration(t *testing.T) {
 			MigrateType: cdbmutil.MigrateTypeUp,
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
-			LogWriter:   func(err error) {},
 			SchemaMigration: schemaMigration{
 				SchemaCfg: schemaConfig{
 					NoRows: true,
@@ -1126,7 +1393,6 @@ func TestApplyCustomMigration(t *testing.T) {
 			MigrateType: cdbmutil.MigrateTypeDown,
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
-			LogWriter:   func(err error) {},
 		},
 	}
 
@@ -1162,7 +1428,6 @@ func TestApplyCustomMigration(t *testing.T) {
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
 			MigrateType: cdbmutil.MigrateTypeUp,
-			LogWriter:   func(err error) {},
 		},
 	}
 
@@ -1195,7 +1460,6 @@ func TestApplyCustomMigration(t *testing.T) {
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
 			MigrateType: cdbmutil.MigrateTypeUp,
-			LogWriter:   func(err error) {},
 			SchemaMigration: schemaMigration{
 				SchemaCfg: schemaConfig{
 					NoRows: true,
@@ -1236,7 +1500,6 @@ func TestApplyCustomMigration(t *testing.T) {
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
 			MigrateType: cdbmutil.MigrateTypeUp,
-			LogWriter:   func(err error) {},
 			FileMigration: func(mig *migrate.Migrate, version int, mt cdbmutil.MigrationsType) error {
 				return nil
 			},
@@ -1280,7 +1543,6 @@ func TestApplyCustomMigration(t *testing.T) {
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
 			MigrateType: cdbmutil.MigrateTypeUp,
-			LogWriter:   func(err error) {},
 			CustomMigrations: map[int]cdbmutil.CustomMigration{
 				2: {
 					Down: func(db webutil.DBInterface) error {
@@ -1328,7 +1590,6 @@ func TestApplyCustomMigration(t *testing.T) {
 			InsertQuery: insertQuery,
 			UpdateQuery: updateQuery,
 			MigrateType: cdbmutil.MigrateTypeDown,
-			LogWriter:   func(err error) {},
 			SchemaMigration: schemaMigration{
 				SchemaCfg: schemaConfig{
 					NoRows: false,
@@ -1356,6 +1617,102 @@ func TestApplyCustomMigration(t *testing.T) {
 	}
 }
 
+func TestRunCustomMigration(t *testing.T) {
+	var err error
+
+	db, mockDB, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	mApp := &CDBM{
+		DB: sqlx.NewDb(db, webutil.Postgres),
+	}
+
+	migrationErr := errors.New("migration error")
+
+	execFunc := func(db webutil.DBInterface) error {
+		_, innerErr := db.Exec("insert into foo(name) values('test');")
+		return innerErr
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating transaction is committed on success
+	mockDB.ExpectBegin()
+	mockDB.ExpectExec("").WillReturnResult(sqlmock.NewResult(1, 1))
+	mockDB.ExpectCommit()
+
+	if err = mApp.runCustomMigration(
+		cdbmutil.CustomMigration{
+			Transaction: true,
+		},
+		execFunc,
+		nil,
+	); err != nil {
+		t.Errorf("should not have error; got %+v", err)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating transaction is rolled back on error
+	mockDB.ExpectBegin()
+	mockDB.ExpectExec("").WillReturnError(migrationErr)
+	mockDB.ExpectRollback()
+
+	if err = mApp.runCustomMigration(
+		cdbmutil.CustomMigration{
+			Transaction: true,
+		},
+		execFunc,
+		nil,
+	); err == nil {
+		t.Errorf("should have error")
+	} else if err.Error() != migrationErr.Error() {
+		t.Errorf("should have %s; got %s\n", migrationErr.Error(), err.Error())
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating query is cancelled once timeout is reached
+	mockDB.ExpectExec("").WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err = mApp.runCustomMigration(
+		cdbmutil.CustomMigration{
+			Timeout: time.Millisecond * 10,
+		},
+		execFunc,
+		nil,
+	); err == nil {
+		t.Errorf("should have error")
+	} else if !strings.Contains(err.Error(), "custom migration exceeded timeout of 10ms") {
+		t.Errorf("should have timeout error; got %s\n", err.Error())
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating statements are logged with rows affected when verbose
+	var buf bytes.Buffer
+
+	log, _ := NewLogger(LevelInfo, LogFormatText, &buf)
+	mApp.MigrateFlags.Verbose = true
+	mockDB.ExpectExec("").WillReturnResult(sqlmock.NewResult(1, 3))
+
+	if err = mApp.runCustomMigration(cdbmutil.CustomMigration{}, execFunc, log.With("version", 2)); err != nil {
+		t.Errorf("should not have error; got %+v", err)
+	}
+
+	if out := buf.String(); !strings.Contains(out, `statement="insert into foo(name) values('test');"`) ||
+		!strings.Contains(out, "rows=3") || !strings.Contains(out, "version=2") {
+		t.Errorf("should have logged statement; got %s\n", out)
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+}
+
 func TestApplyFileMigration(t *testing.T) {
 	var err error
 	var mApp *CDBM
@@ -1403,7 +1760,6 @@ func TestApplyFileMigration(t *testing.T) {
 		migrateCfg: migrateState{
 			MigrateType: cdbmutil.MigrateTypeUp,
 			UpdateQuery: updateQuery,
-			LogWriter:   func(err error) {},
 			FileMigration: func(mig *migrate.Migrate, version int, mt cdbmutil.MigrationsType) error {
 				return nil
 			},
@@ -1428,7 +1784,6 @@ func TestApplyFileMigration(t *testing.T) {
 		migrateCfg: migrateState{
 			MigrateType: cdbmutil.MigrateTypeUp,
 			UpdateQuery: updateQuery,
-			LogWriter:   func(err error) {},
 			FileMigration: func(mig *migrate.Migrate, version int, mt cdbmutil.MigrationsType) error {
 				if mt == cdbmutil.MigrateTypeUp {
 					return fmt.Errorf("file migration error")
@@ -1456,7 +1811,6 @@ func TestApplyFileMigration(t *testing.T) {
 		migrateCfg: migrateState{
 			MigrateType: cdbmutil.MigrateTypeDown,
 			UpdateQuery: updateQuery,
-			LogWriter:   func(err error) {},
 			FileMigration: func(mig *migrate.Migrate, version int, mt cdbmutil.MigrationsType) error {
 				return fmt.Errorf("file migration error")
 			},
@@ -1487,7 +1841,6 @@ func TestApplyFileMigration(t *testing.T) {
 		migrateCfg: migrateState{
 			MigrateType: cdbmutil.MigrateTypeUp,
 			UpdateQuery: updateQuery,
-			LogWriter:   func(err error) {},
 			SchemaMigration: schemaMigration{
 				SchemaCfg: schemaConfig{
 					Dirty: true,
@@ -1540,7 +1893,6 @@ func TestApplyFileMigration(t *testing.T) {
 		migrateCfg: migrateState{
 			MigrateType: cdbmutil.MigrateTypeUp,
 			UpdateQuery: updateQuery,
-			LogWriter:   func(err error) {},
 			SchemaMigration: schemaMigration{
 				SchemaCfg: schemaConfig{
 					Dirty: true,
@@ -1571,7 +1923,6 @@ func TestApplyFileMigration(t *testing.T) {
 		migrateCfg: migrateState{
 			MigrateType: cdbmutil.MigrateTypeUp,
 			UpdateQuery: updateQuery,
-			LogWriter:   func(err error) {},
 			SchemaMigration: schemaMigration{
 				SchemaCfg: schemaConfig{
 					Dirty: true,
@@ -1638,7 +1989,6 @@ func TestRunMigrationConfigs(t *testing.T) {
 		DB: db,
 		migrateCfg: migrateState{
 			TargetVersion:   2,
-			LogWriter:       func(err error) {},
 			MigrateType:     cdbmutil.MigrateTypeUp,
 			InsertQuery:     insertQuery,
 			UpdateQuery:     updateQuery,
@@ -1685,7 +2035,6 @@ func TestRunMigrationConfigs(t *testing.T) {
 		DB: db,
 		migrateCfg: migrateState{
 			TargetVersion:   2,
-			LogWriter:       func(err error) {},
 			MigrateType:     cdbmutil.MigrateTypeUp,
 			InsertQuery:     insertQuery,
 			UpdateQuery:     updateQuery,
@@ -1753,7 +2102,6 @@ func TestRunMigrationConfigs(t *testing.T) {
 		DB: db,
 		migrateCfg: migrateState{
 			TargetVersion:   2,
-			LogWriter:       func(err error) {},
 			MigrateType:     cdbmutil.MigrateTypeUp,
 			InsertQuery:     insertQuery,
 			UpdateQuery:     updateQuery,
@@ -1803,7 +2151,6 @@ func TestRunMigrationConfigs(t *testing.T) {
 		DB: db,
 		migrateCfg: migrateState{
 			TargetVersion:   2,
-			LogWriter:       func(err error) {},
 			MigrateType:     cdbmutil.MigrateTypeDown,
 			InsertQuery:     insertQuery,
 			UpdateQuery:     updateQuery,
@@ -1880,7 +2227,6 @@ func TestRunMigrationConfigs(t *testing.T) {
 		DB: db,
 		migrateCfg: migrateState{
 			TargetVersion: 2,
-			LogWriter:     func(err error) {},
 			MigrateType:   cdbmutil.MigrateTypeDown,
 			InsertQuery:   insertQuery,
 			UpdateQuery:   updateQuery,
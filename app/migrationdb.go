@@ -0,0 +1,224 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TravisS25/cdbm/cdbmutil"
+
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+)
+
+// migrateLogRegex matches migration golang-migrate logs ie. "1/u create_users"
+// along with read and run durations of "Finished" logs
+var migrateLogRegex = regexp.MustCompile(`(\d+)/(u|d) (\S+)(?: \(read (\S+), ran (\S+)\))?`)
+
+// contextQuerierExec is implemented by both *sqlx.DB and *sqlx.Tx and is used
+// to run queries with context
+type contextQuerierExec interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+	QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// migrationDB is the webutil.DBInterface passed to custom migrations
+//
+// Every query is ran with the migration's context, so it is cancelled once
+// a timeout is reached, and through a transaction if one was started
+//
+// If log is set, every query is logged along with its duration and rows affected
+// and rows affected by every Exec are totaled for trace span of migration
+//
+// Database is not embedded so no method can escape the context or transaction
+type migrationDB struct {
+	db  *sqlx.DB
+	ctx context.Context
+	qe  contextQuerierExec
+	log Logger
+
+	// rowsAffected is total rows affected by every Exec
+	rowsAffected int64
+}
+
+// newMigrationDB returns *migrationDB that runs queries through qe with given
+// context and logs them to given Logger if not nil
+func newMigrationDB(ctx context.Context, db *sqlx.DB, qe contextQuerierExec, log Logger) *migrationDB {
+	return &migrationDB{
+		db:  db,
+		ctx: ctx,
+		qe:  qe,
+		log: log,
+	}
+}
+
+var _ webutil.DBInterface = (*migrationDB)(nil)
+
+// Context returns context of custom migration and is used by cdbmutil.MigrationContext
+func (m *migrationDB) Context() context.Context {
+	return m.ctx
+}
+
+// logStatement logs given query that started at given time if log is set
+//
+// rows should be -1 if rows affected is not known
+func (m *migrationDB) logStatement(query string, start time.Time, rows int64, err error) {
+	if m.log != nil {
+		logStatement(m.log, query, time.Since(start), rows, err)
+	}
+}
+
+func (m *migrationDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := m.qe.ExecContext(m.ctx, query, args...)
+	rows := int64(-1)
+
+	if err == nil {
+		if affected, affectedErr := res.RowsAffected(); affectedErr == nil {
+			rows = affected
+			m.rowsAffected += affected
+		}
+	}
+
+	m.logStatement(query, start, rows, err)
+	return res, err
+}
+
+func (m *migrationDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := m.qe.QueryContext(m.ctx, query, args...)
+	m.logStatement(query, start, -1, err)
+	return rows, err
+}
+
+func (m *migrationDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := m.qe.QueryRowContext(m.ctx, query, args...)
+	m.logStatement(query, start, -1, row.Err())
+	return row
+}
+
+func (m *migrationDB) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
+	start := time.Now()
+	rows, err := m.qe.QueryxContext(m.ctx, query, args...)
+	m.logStatement(query, start, -1, err)
+	return rows, err
+}
+
+func (m *migrationDB) QueryRowx(query string, args ...interface{}) *sqlx.Row {
+	start := time.Now()
+	row := m.qe.QueryRowxContext(m.ctx, query, args...)
+	m.logStatement(query, start, -1, row.Err())
+	return row
+}
+
+func (m *migrationDB) Get(dest interface{}, query string, args ...interface{}) error {
+	start := time.Now()
+	err := m.qe.GetContext(m.ctx, dest, query, args...)
+	m.logStatement(query, start, -1, err)
+	return err
+}
+
+func (m *migrationDB) Select(dest interface{}, query string, args ...interface{}) error {
+	start := time.Now()
+	err := m.qe.SelectContext(m.ctx, dest, query, args...)
+	m.logStatement(query, start, -1, err)
+	return err
+}
+
+// Beginx starts transaction with context of migration
+//
+// Returns error if custom migration already runs in a transaction as
+// transactions can't be nested
+func (m *migrationDB) Beginx() (*sqlx.Tx, error) {
+	if _, ok := m.qe.(*sqlx.Tx); ok {
+		return nil, fmt.Errorf("custom migration already runs in a transaction")
+	}
+
+	return m.db.BeginTxx(m.ctx, nil)
+}
+
+func (m *migrationDB) Rebind(query string) string {
+	return m.db.Rebind(query)
+}
+
+// Close returns error as database is owned by cdbm and is still used once
+// custom migration is done
+func (m *migrationDB) Close() error {
+	return fmt.Errorf("can't close database within custom migration")
+}
+
+// logStatement logs given statement with its duration and rows affected, if
+// known, to given Logger and displays it to stdout
+func logStatement(log Logger, statement string, d time.Duration, rows int64, err error) {
+	statement = compactStatement(statement)
+	fields := []interface{}{"statement", statement, "duration", d}
+	display := fmt.Sprintf("  %s (%s", statement, d)
+
+	if rows >= 0 {
+		fields = append(fields, "rows", rows)
+		display += fmt.Sprintf(", %d rows", rows)
+	}
+
+	if err != nil {
+		fields = append(fields, "error", err)
+		display += ", failed"
+	}
+
+	log.Info("executed statement", fields...)
+	fmt.Println(display + ")")
+}
+
+// compactStatement collapses whitespace of given statement into single spaces
+// so it fits on one line
+func compactStatement(statement string) string {
+	return strings.Join(strings.Fields(statement), " ")
+}
+
+// migrateLogger is migrate.Logger that logs steps of file migrations reported
+// by golang-migrate to Logger and displays them to stdout
+//
+// golang-migrate only reports once per migration file, so statements within
+// file are never logged individually
+type migrateLogger struct {
+	log Logger
+}
+
+func (m *migrateLogger) Printf(format string, v ...interface{}) {
+	step := strings.TrimSpace(fmt.Sprintf(format, v...))
+	fields := []interface{}{"kind", migrationKindFile, "step", step}
+
+	if match := migrateLogRegex.FindStringSubmatch(step); match != nil {
+		version, _ := strconv.Atoi(match[1])
+		direction := cdbmutil.MigrateTypeUp
+
+		if match[2] == "d" {
+			direction = cdbmutil.MigrateTypeDown
+		}
+
+		fields = append(fields, "version", version, "direction", direction, "name", match[3])
+
+		if read, err := time.ParseDuration(match[4]); err == nil {
+			fields = append(fields, "read", read)
+		}
+		if ran, err := time.ParseDuration(match[5]); err == nil {
+			fields = append(fields, "duration", ran)
+		}
+	}
+
+	m.log.Info("file migration step", fields...)
+	fmt.Println("  " + step)
+}
+
+func (m *migrateLogger) Verbose() bool {
+	return true
+}
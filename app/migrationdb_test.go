@@ -0,0 +1,87 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestMigrateLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	log, _ := NewLogger(LevelInfo, LogFormatText, &buf)
+	l := &migrateLogger{log: log}
+
+	// Validating that version, direction and durations are parsed from golang-migrate logs
+	l.Printf("Finished %v (read %v, ran %v)\n", "3/d create_users", "1.5ms", "20ms")
+
+	out := buf.String()
+
+	for _, field := range []string{"version=3", "direction=Down", "name=create_users", "read=1.5ms", "duration=20ms"} {
+		if !strings.Contains(out, field) {
+			t.Errorf("should have field %s; got %s\n", field, out)
+		}
+	}
+}
+
+func TestMigrationDB(t *testing.T) {
+	var err error
+
+	db, mockDB, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	sqlxDB := sqlx.NewDb(db, webutil.Postgres)
+
+	// Validating that transaction is started when custom migration is not
+	// in a transaction
+	mdb := newMigrationDB(context.Background(), sqlxDB, sqlxDB, nil)
+
+	mockDB.ExpectBegin()
+	mockDB.ExpectRollback()
+
+	tx, err := mdb.Beginx()
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Errorf("should not have error; got %+v", err)
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that transaction can't be nested and database can't be closed
+	mockDB.ExpectBegin()
+
+	if tx, err = sqlxDB.Beginx(); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	mdb = newMigrationDB(context.Background(), sqlxDB, tx, nil)
+
+	if _, err = mdb.Beginx(); err == nil {
+		t.Errorf("should have error")
+	}
+	if err = mdb.Close(); err == nil {
+		t.Errorf("should have error")
+	}
+	if query := mdb.Rebind("select ?"); query != "select $1" {
+		t.Errorf("should have rebound query; got %s", query)
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+}
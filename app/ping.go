@@ -0,0 +1,187 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	// PingOK is status of entry that was connected to and queried
+	PingOK = "ok"
+
+	// PingAuthFailed is status of entry that was reached but rejected
+	// given user or password
+	PingAuthFailed = "auth failed"
+
+	// PingUnreachable is status of entry that couldn't be reached
+	PingUnreachable = "unreachable"
+
+	// PingError is status of entry that was reached but returned any other error
+	PingError = "error"
+
+	// PingConfigError is status of entry whose setting is invalid or whose
+	// password couldn't be resolved so it was never tried
+	PingConfigError = "config error"
+)
+
+// settingError is error of resolving connection setting before connecting
+type settingError struct {
+	err error
+}
+
+func (e *settingError) Error() string {
+	return e.err.Error()
+}
+
+// namedSetting is a connection setting along with name of where it was
+// configured ie. database_config.prod[0]
+type namedSetting struct {
+	name    string
+	setting DatabaseSetting
+}
+
+// namedSettings returns connection set by root flags, if any, along with every
+// entry of CDBM#DatabaseConfig sorted by name
+//
+// Entries of CDBM#DatabaseConfig are still returned if root flags connection
+// returns error
+func (cdbm *CDBM) namedSettings() ([]namedSetting, error) {
+	namedSettings := make([]namedSetting, 0)
+	rootSettings, err := cdbm.rootFlagsSettings()
+
+	for _, setting := range rootSettings {
+		namedSettings = append(namedSettings, namedSetting{
+			name:    "root_flags connection",
+			setting: setting,
+		})
+	}
+
+	for _, name := range cdbm.databaseConfigNames() {
+		for i, setting := range cdbm.DatabaseConfig[name] {
+			namedSettings = append(namedSettings, namedSetting{
+				name:    fmt.Sprintf("database_config.%s[%d]", name, i),
+				setting: setting,
+			})
+		}
+	}
+
+	return namedSettings, err
+}
+
+// pingStatus returns status of ping based on given error
+//
+// Errors of resolving setting are reported as PingConfigError, postgres errors
+// of class 28 (invalid authorization) as PingAuthFailed and any error not
+// returned by server as PingUnreachable
+func pingStatus(err error) string {
+	if err == nil {
+		return PingOK
+	}
+
+	if _, ok := errors.Cause(err).(*settingError); ok {
+		return PingConfigError
+	}
+
+	if pqErr, ok := errors.Cause(err).(*pq.Error); ok {
+		if pqErr.Code.Class() == "28" {
+			return PingAuthFailed
+		}
+
+		return PingError
+	}
+
+	return PingUnreachable
+}
+
+// Ping connects to database set by root flags and every entry of database_config
+// and displays reachability, auth result, latency and server version of each
+//
+// Displays: "%-12s %s %s" as status (ok | auth failed | unreachable | error |
+// config error), entry name and setting followed by latency and version if
+// connected, else error
+//
+// Invalid root flags connection is displayed as config error and entries of
+// database_config are still pinged
+//
+// Returns error if any entry couldn't be connected to
+func Ping(cfg RootFlagsConfig) error {
+	cdbm, err := loadCDBM(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	if err = cdbm.prepare(); err != nil {
+		return err
+	}
+
+	total := 0
+	failed := 0
+	namedSettings, err := cdbm.namedSettings()
+
+	if err != nil {
+		total++
+		failed++
+		fmt.Printf("%-12s %s: %v\n", PingConfigError, "root_flags connection", cdbm.redactErr(err))
+	} else if len(namedSettings) == 0 {
+		return fmt.Errorf("no database connections are configured")
+	}
+
+	for _, ns := range namedSettings {
+		name := ns.name + " " + describeSetting(ns.setting)
+		latency, version, err := cdbm.ping(ns.setting)
+		status := pingStatus(err)
+		total++
+
+		if err != nil {
+			failed++
+			fmt.Printf("%-12s %s: %v\n", status, name, cdbm.redactErr(err))
+			continue
+		}
+
+		fmt.Printf("%-12s %s latency=%s version=%s\n", status, name, latency, version)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d database connection(s) failed", failed, total)
+	}
+
+	return nil
+}
+
+// ping connects with given setting and returns round trip time of querying
+// server version along with the version
+//
+// Errors of resolving given setting are returned as *settingError
+func (cdbm *CDBM) ping(setting DatabaseSetting) (time.Duration, string, error) {
+	var version string
+
+	settings, err := cdbm.resolveSettings([]DatabaseSetting{setting})
+
+	if err != nil {
+		return 0, "", &settingError{err: err}
+	}
+
+	db, err := newDB(settings[0], cdbm.DBProtocolCfg.DatabaseType, cdbm.RootFlags.Session)
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	if db == nil {
+		return 0, "", fmt.Errorf("no connection to database was established")
+	}
+
+	defer db.Close()
+
+	start := time.Now()
+
+	if err = db.QueryRow("select version();").Scan(&version); err != nil {
+		return 0, "", errors.WithStack(err)
+	}
+
+	return time.Since(start).Round(time.Microsecond), version, nil
+}
@@ -0,0 +1,28 @@
+package app
+
+import (
+	"net"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+func TestPingStatus(t *testing.T) {
+	statuses := []struct {
+		err    error
+		status string
+	}{
+		{nil, PingOK},
+		{errors.WithStack(&pq.Error{Code: "28P01"}), PingAuthFailed},
+		{&pq.Error{Code: "3D000"}, PingError},
+		{errors.WithStack(&net.OpError{Op: "dial"}), PingUnreachable},
+		{&settingError{err: errors.New("password env PGPASS is not set")}, PingConfigError},
+	}
+
+	for _, s := range statuses {
+		if status := pingStatus(s.err); status != s.status {
+			t.Errorf("should have status %s for error %v; got %s\n", s.status, s.err, status)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/TravisS25/cdbm/cdbmutil"
+)
+
+// VerifyReversible verifies that down migration of every version faithfully
+// reverses its up migration
+//
+// Scratch database is created with cdbmutil.GetNewDatabase and every version,
+// file and custom, is applied up, down and up again.  Schema snapshot after
+// down must match snapshot before up and snapshot after up again must match
+// snapshot after first up.  Versions up to baseline of squashed migrations
+// can't be reversed so they are applied without being verified
+//
+// Returns error if any version is not reversible
+func (cdbm *CDBM) VerifyReversible(
+	getMigFunc cdbmutil.GetMigrationFunc,
+	fMigFunc cdbmutil.FileMigrationFunc,
+	cMigrations map[int]cdbmutil.CustomMigration,
+) error {
+	var err error
+
+	if err = cdbm.checkMigrationsProtocol(); err != nil {
+		return err
+	}
+
+	scratch, _, dropScratch, err := cdbm.newScratch()
+
+	if err != nil {
+		return err
+	}
+
+	defer dropScratch()
+
+	return scratch.verifyReversible(cdbm.logger(), cMigrations, func(version int) error {
+		return scratch.migrateScratch(version, getMigFunc, fMigFunc, cMigrations)
+	})
+}
+
+// verifyReversible applies every version of scratch database up, down and up
+// again with given func that migrates it to given version, comparing snapshots
+// of schema as described by CDBM#VerifyReversible
+func (cdbm *CDBM) verifyReversible(
+	log Logger,
+	cMigrations map[int]cdbmutil.CustomMigration,
+	migrateTo func(version int) error,
+) error {
+	var err error
+
+	// Every version is verified so none are skipped by tags
+	cdbm.MigrateFlags.SkipTags = nil
+	cdbm.migrateCfg.CustomMigrations = cMigrations
+
+	cfgs, err := cdbm.verifyFilesAndMigrations()
+
+	if err != nil {
+		return err
+	}
+
+	baseline := baselineVersion(cfgs)
+	previous := 0
+
+	if baseline > 0 {
+		if err = migrateTo(baseline); err != nil {
+			return err
+		}
+
+		fmt.Printf("Version %d is baseline; skipped\n", baseline)
+		previous = baseline
+	}
+
+	before, err := cdbm.Snapshot()
+
+	if err != nil {
+		return err
+	}
+
+	broken := make([]int, 0)
+
+	for _, cfg := range cfgs {
+		if cfg.Version <= baseline {
+			continue
+		}
+
+		versionLog := log.With("version", cfg.Version, "kind", cfg.kind())
+
+		if err = migrateTo(cfg.Version); err != nil {
+			return fmt.Errorf("version %d: can't apply up migration: %v", cfg.Version, err)
+		}
+
+		afterUp, err := cdbm.Snapshot()
+
+		if err != nil {
+			return err
+		}
+
+		if err = migrateTo(previous); err != nil {
+			return fmt.Errorf("version %d: can't apply down migration: %v", cfg.Version, err)
+		}
+
+		afterDown, err := cdbm.Snapshot()
+
+		if err != nil {
+			return err
+		}
+
+		if err = migrateTo(cfg.Version); err != nil {
+			return fmt.Errorf("version %d: can't apply up migration after down migration: %v", cfg.Version, err)
+		}
+
+		afterRedo, err := cdbm.Snapshot()
+
+		if err != nil {
+			return err
+		}
+
+		diffs := compareRoundTrip(before, afterUp, afterDown, afterRedo)
+
+		versionLog.Info("verified round trip", "differences", len(diffs))
+
+		if len(diffs) == 0 {
+			fmt.Printf("Version %d is reversible\n", cfg.Version)
+		} else {
+			fmt.Printf("Version %d is not reversible:\n", cfg.Version)
+
+			for _, diff := range diffs {
+				fmt.Printf("  %s\n", diff)
+			}
+
+			broken = append(broken, cfg.Version)
+		}
+
+		before = afterRedo
+		previous = cfg.Version
+	}
+
+	if len(broken) > 0 {
+		return fmt.Errorf("found versions %v whose down migration doesn't reverse up migration", broken)
+	}
+
+	return nil
+}
+
+// compareRoundTrip returns differences of up, down and up again round trip of
+// version where snapshot after down must match snapshot before up and snapshot
+// after up again must match snapshot after first up
+func compareRoundTrip(before, afterUp, afterDown, afterRedo Snapshot) []string {
+	diffs := make([]string, 0)
+
+	for _, diff := range diffSnapshots(before, afterDown) {
+		diffs = append(diffs, "after down: "+diff)
+	}
+
+	for _, diff := range diffSnapshots(afterUp, afterRedo) {
+		diffs = append(diffs, "after up again: "+diff)
+	}
+
+	return diffs
+}
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+)
+
+// expectEmptySnapshot sets given mock to return no rows for snapshot queries
+func expectEmptySnapshot(mockDB sqlmock.Sqlmock) {
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"nspname", "relname", "attname", "format_type", "attnotnull", "default"}),
+	)
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"nspname", "relname", "conname", "contype", "def"}),
+	)
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"schemaname", "tablename", "indexname", "indexdef"}),
+	)
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"schemaname", "viewname", "definition"}),
+	)
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"nspname", "proname", "args", "result", "lanname", "prosrc"}),
+	)
+}
+
+func TestVerifyReversible(t *testing.T) {
+	var err error
+
+	dir, err := ioutil.TempDir("", "cdbm-reversible")
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"000001_baseline.up.sql": "-- " + cdbmutil.BaselineHeader + "\ncreate table foo (id int);",
+		"000002_users.up.sql":    "-- cdbm:tags slow\ncreate table users (id int);",
+		"000002_users.down.sql":  "drop table users;",
+	}
+
+	for name, body := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(body), os.ModePerm); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	noop := func(db webutil.DBInterface) error {
+		return nil
+	}
+	cMigrations := map[int]cdbmutil.CustomMigration{
+		3: {Up: noop, Down: noop, Tags: []string{"slow"}},
+	}
+
+	db, mockDB, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	c := &CDBM{
+		DB: sqlx.NewDb(db, webutil.Postgres),
+		MigrateFlags: MigrateFlagsConfig{
+			MigrationsProtocol: cdbmutil.FileProtocol,
+			MigrationsDir:      dir,
+			SkipTags:           []string{"slow"},
+		},
+	}
+
+	targets := make([]int, 0)
+	migrateTo := func(version int) error {
+		if len(c.MigrateFlags.SkipTags) > 0 {
+			t.Errorf("should not skip tags while verifying; got %v", c.MigrateFlags.SkipTags)
+		}
+
+		targets = append(targets, version)
+		return nil
+	}
+
+	// Validating that baseline is applied without being verified, every
+	// version after it is applied up, down to previous version and up again
+	// and version whose down migration leaves schema changed is reported
+	expectSnapshot(mockDB)
+
+	// Version 2
+	expectSnapshot(mockDB)
+	expectSnapshot(mockDB)
+	expectSnapshot(mockDB)
+
+	// Version 3
+	expectSnapshot(mockDB)
+	expectEmptySnapshot(mockDB)
+	expectSnapshot(mockDB)
+
+	err = c.verifyReversible(nopLogger{}, cMigrations, migrateTo)
+
+	if err == nil || err.Error() != "found versions [3] whose down migration doesn't reverse up migration" {
+		t.Errorf("should have version 3 not reversible; got %v", err)
+	}
+
+	if expected := []int{1, 2, 1, 2, 3, 2, 3}; !reflect.DeepEqual(targets, expected) {
+		t.Errorf("should have migrated to versions %v; got %v", expected, targets)
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that first version is migrated down to version 0 without
+	// baseline
+	if err = os.Remove(filepath.Join(dir, "000001_baseline.up.sql")); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	targets = make([]int, 0)
+	c.MigrateFlags.SkipTags = []string{"slow"}
+
+	for i := 0; i < 7; i++ {
+		expectSnapshot(mockDB)
+	}
+
+	if err = c.verifyReversible(nopLogger{}, cMigrations, migrateTo); err != nil {
+		t.Errorf("should not have error; got %+v", err)
+	}
+
+	if expected := []int{2, 0, 2, 3, 2, 3}; !reflect.DeepEqual(targets, expected) {
+		t.Errorf("should have migrated to versions %v; got %v", expected, targets)
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+}
+
+func TestCompareRoundTrip(t *testing.T) {
+	before := Snapshot{
+		Tables: []SnapshotTable{
+			{Schema: "public", Name: "users"},
+		},
+	}
+	afterUp := Snapshot{
+		Tables: []SnapshotTable{
+			{Schema: "public", Name: "users"},
+			{
+				Schema: "public",
+				Name:   "orders",
+				Indexes: []SnapshotIndex{
+					{Name: "orders_user_id_idx", Definition: "CREATE INDEX orders_user_id_idx ON public.orders USING btree (user_id)"},
+				},
+			},
+		},
+	}
+
+	// Validating that faithful round trip has no differences
+	if diffs := compareRoundTrip(before, afterUp, before, afterUp); len(diffs) != 0 {
+		t.Errorf("should not have differences; got %v", diffs)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that down migration leaving and dropping entries is reported
+	afterDown := Snapshot{
+		Tables: []SnapshotTable{
+			{Schema: "public", Name: "orders"},
+		},
+	}
+	afterRedo := Snapshot{
+		Tables: []SnapshotTable{
+			{Schema: "public", Name: "users"},
+			{Schema: "public", Name: "orders"},
+		},
+	}
+
+	expectedDiffs := []string{
+		"after down: missing table public.users",
+		"after down: unexpected table public.orders",
+		"after up again: missing index public.orders.orders_user_id_idx",
+	}
+
+	if diffs := compareRoundTrip(before, afterUp, afterDown, afterRedo); !reflect.DeepEqual(diffs, expectedDiffs) {
+		t.Errorf("should have differences %q; got %q", expectedDiffs, diffs)
+	}
+}
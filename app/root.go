@@ -1,12 +1,21 @@
 package app
 
+import "time"
+
 type RootFlagsConfig struct {
-	DBProtocol    string `yaml:"db_protocol" mapstructure:"db_protocol"`
-	EnvVar        string `yaml:"env_var" mapstructure:"env_var"`
-	Database      string `yaml:"database" mapstructure:"database"`
-	Host          string `yaml:"host" mapstructure:"host"`
-	User          string `yaml:"user" mapstructure:"user"`
-	Password      string `yaml:"password" mapstructure:"password"`
+	DBProtocol string `yaml:"db_protocol" mapstructure:"db_protocol"`
+	EnvVar     string `yaml:"env_var" mapstructure:"env_var"`
+	Database   string `yaml:"database" mapstructure:"database"`
+	Host       string `yaml:"host" mapstructure:"host"`
+	User       string `yaml:"user" mapstructure:"user"`
+	Password   string `yaml:"password" mapstructure:"password"`
+
+	// PasswordFile, PasswordEnv and PasswordCommand are alternatives to Password
+	// that read password from file, enviroment variable or stdout of command
+	PasswordFile    string `yaml:"password_file" mapstructure:"password_file"`
+	PasswordEnv     string `yaml:"password_env" mapstructure:"password_env"`
+	PasswordCommand string `yaml:"password_command" mapstructure:"password_command"`
+
 	SSLMode       string `yaml:"ssl_mode" mapstructure:"ssl_mode"`
 	SSLRootCert   string `yaml:"ssl_root_cert" mapstructure:"ssl_root_cert"`
 	SSLKey        string `yaml:"ssl_key" mapstructure:"ssl_key"`
@@ -14,22 +23,71 @@ type RootFlagsConfig struct {
 	Port          int    `yaml:"port" mapstructure:"port"`
 	SSL           bool   `yaml:"ssl" mapstructure:"ssl"`
 	UseFileOnFail bool   `yaml:"use_file_on_fail" mapstructure:"use_file_on_fail"`
+	Target        string `yaml:"target" mapstructure:"target"`
+
+	// DSN is url or key/value connection string of database to connect to
+	// instead of setting Host, Port, User, Password and Database
+	//
+	// If not set, DATABASE_URL enviroment variable is used if set and no
+	// other connection is set by Database, Host, Target or config file
+	DSN string `yaml:"database_url" mapstructure:"database_url"`
+
+	// ConnectRetries is number of times to retry connecting to database after
+	// every connection setting fails
+	//
+	// If 0 and ConnectTimeout is set, retries until ConnectTimeout runs out
+	ConnectRetries int `yaml:"connect_retries" mapstructure:"connect_retries"`
+
+	// ConnectBackoff is how long to wait before first retry and is doubled after
+	// each retry up to 30s
+	ConnectBackoff time.Duration `yaml:"connect_backoff" mapstructure:"connect_backoff"`
+
+	// ConnectTimeout is the total time budget for connecting to database
+	ConnectTimeout time.Duration `yaml:"connect_timeout" mapstructure:"connect_timeout"`
+
+	// Session is connection pool and session settings of connections to database
+	Session SessionConfig `yaml:"session" mapstructure:"session"`
+
+	// LogLevel is min level of entries written by CDBM#Logger
+	// ie. debug | info | warn | error
+	//
+	// Defaults to info
+	LogLevel string `yaml:"log_level" mapstructure:"log_level"`
+
+	// LogFormat is format of entries written by CDBM#Logger ie. text | json
+	//
+	// Defaults to text
+	LogFormat string `yaml:"log_format" mapstructure:"log_format"`
 }
 
 type RootNameConfig struct {
-	DBProtocol    FlagName
-	Env           FlagName
-	Database      FlagName
-	Host          FlagName
-	User          FlagName
-	Password      FlagName
-	Port          FlagName
-	SSLMode       FlagName
-	SSLRootCert   FlagName
-	SSLKey        FlagName
-	SSLCert       FlagName
-	SSL           FlagName
-	UseFileOnFail FlagName
+	DBProtocol      FlagName
+	Env             FlagName
+	Database        FlagName
+	Host            FlagName
+	User            FlagName
+	Password        FlagName
+	PasswordFile    FlagName
+	PasswordEnv     FlagName
+	PasswordCommand FlagName
+	Port            FlagName
+	SSLMode         FlagName
+	SSLRootCert     FlagName
+	SSLKey          FlagName
+	SSLCert         FlagName
+	SSL             FlagName
+	UseFileOnFail   FlagName
+	Target          FlagName
+	DSN             FlagName
+	ConnectRetries  FlagName
+	ConnectBackoff  FlagName
+	ConnectTimeout  FlagName
+	MaxOpenConns    FlagName
+	MaxIdleConns    FlagName
+	ConnMaxLifetime FlagName
+	SessionParam    FlagName
+	LogLevel        FlagName
+	LogFormat       FlagName
 }
 
 var DefaultRootNameCfg = RootNameConfig{
@@ -57,6 +115,15 @@ var DefaultRootNameCfg = RootNameConfig{
 		LongHand:  "password",
 		ShortHand: "w",
 	},
+	PasswordFile: FlagName{
+		LongHand: "password-file",
+	},
+	PasswordEnv: FlagName{
+		LongHand: "password-env",
+	},
+	PasswordCommand: FlagName{
+		LongHand: "password-command",
+	},
 	Port: FlagName{
 		LongHand:  "port",
 		ShortHand: "p",
@@ -85,4 +152,37 @@ var DefaultRootNameCfg = RootNameConfig{
 		LongHand:  "use-file-on-fail",
 		ShortHand: "f",
 	},
+	Target: FlagName{
+		LongHand: "target",
+	},
+	DSN: FlagName{
+		LongHand: "dsn",
+	},
+	ConnectRetries: FlagName{
+		LongHand: "connect-retries",
+	},
+	ConnectBackoff: FlagName{
+		LongHand: "connect-backoff",
+	},
+	ConnectTimeout: FlagName{
+		LongHand: "connect-timeout",
+	},
+	MaxOpenConns: FlagName{
+		LongHand: "max-open-conns",
+	},
+	MaxIdleConns: FlagName{
+		LongHand: "max-idle-conns",
+	},
+	ConnMaxLifetime: FlagName{
+		LongHand: "conn-max-lifetime",
+	},
+	SessionParam: FlagName{
+		LongHand: "session-param",
+	},
+	LogLevel: FlagName{
+		LongHand: "log-level",
+	},
+	LogFormat: FlagName{
+		LongHand: "log-format",
+	},
 }
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/TravisS25/webutil/webutil"
+)
+
+const (
+	// redactedPassword is what passwords are replaced with in errors and logs
+	redactedPassword = "********"
+)
+
+// DatabaseSetting is webutil.DatabaseSetting with other sources a password can
+// be read from so passwords don't have to be stored in plaintext
+//
+// Only one of Password, PasswordFile, PasswordEnv or PasswordCommand can be set
+type DatabaseSetting struct {
+	webutil.DatabaseSetting `yaml:",inline" mapstructure:",squash"`
+
+	// PasswordFile is file path whose contents are used as password
+	PasswordFile string `yaml:"password_file" mapstructure:"password_file"`
+
+	// PasswordEnv is enviroment variable whose value is used as password
+	PasswordEnv string `yaml:"password_env" mapstructure:"password_env"`
+
+	// PasswordCommand is shell command whose stdout is used as password
+	PasswordCommand string `yaml:"password_command" mapstructure:"password_command"`
+}
+
+// resolvePassword returns webutil.DatabaseSetting of given setting with its
+// password read from whichever password source is set
+//
+// Trailing newlines are trimmed from password files and command output
+func resolvePassword(setting DatabaseSetting) (webutil.DatabaseSetting, error) {
+	dbSetting := setting.DatabaseSetting
+	sources := 0
+
+	for _, source := range []string{
+		setting.Password,
+		setting.PasswordFile,
+		setting.PasswordEnv,
+		setting.PasswordCommand,
+	} {
+		if source != "" {
+			sources++
+		}
+	}
+
+	if sources > 1 {
+		return dbSetting, fmt.Errorf(
+			"only one of password, password file, password env or password command can be set for host '%s'",
+			setting.Host,
+		)
+	}
+
+	switch {
+	case setting.PasswordFile != "":
+		password, err := ioutil.ReadFile(setting.PasswordFile)
+
+		if err != nil {
+			return dbSetting, fmt.Errorf("can't read password file for host '%s': %v", setting.Host, err)
+		}
+
+		dbSetting.Password = strings.TrimRight(string(password), "\r\n")
+	case setting.PasswordEnv != "":
+		password, ok := os.LookupEnv(setting.PasswordEnv)
+
+		if !ok {
+			return dbSetting, fmt.Errorf(
+				"password env '%s' for host '%s' is not set",
+				setting.PasswordEnv,
+				setting.Host,
+			)
+		}
+
+		dbSetting.Password = password
+	case setting.PasswordCommand != "":
+		var stdout bytes.Buffer
+
+		passwordCmd := exec.Command("sh", "-c", setting.PasswordCommand)
+		passwordCmd.Stdout = &stdout
+		passwordCmd.Stderr = os.Stderr
+
+		if err := passwordCmd.Run(); err != nil {
+			return dbSetting, fmt.Errorf("password command for host '%s' failed: %v", setting.Host, err)
+		}
+
+		dbSetting.Password = strings.TrimRight(stdout.String(), "\r\n")
+	}
+
+	return dbSetting, nil
+}
+
+// redact replaces every password of settings used to connect to database
+// found in given message with redactedPassword
+func (cdbm *CDBM) redact(msg string) string {
+	for _, password := range cdbm.passwords {
+		msg = strings.Replace(msg, password, redactedPassword, -1)
+	}
+
+	return msg
+}
+
+// redactErr returns error whose message has every password redacted
+//
+// Returns nil if given error is nil
+func (cdbm *CDBM) redactErr(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if msg := err.Error(); cdbm.redact(msg) != msg {
+		return fmt.Errorf("%s", cdbm.redact(msg))
+	}
+
+	return err
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TravisS25/webutil/webutil"
+)
+
+func TestResolvePassword(t *testing.T) {
+	var err error
+
+	dir, err := ioutil.TempDir("", "cdbm-secret")
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	passwordFile := filepath.Join(dir, "password")
+
+	if err = ioutil.WriteFile(passwordFile, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	os.Setenv("CDBM_TEST_PASSWORD", "env-secret")
+	defer os.Unsetenv("CDBM_TEST_PASSWORD")
+
+	// --------------------------------------------------------------------------
+
+	// Validating each password source
+	validSettings := []struct {
+		setting  DatabaseSetting
+		password string
+	}{
+		{
+			setting: DatabaseSetting{
+				DatabaseSetting: webutil.DatabaseSetting{
+					BaseAuthSetting: webutil.BaseAuthSetting{
+						Password: "plain-secret",
+					},
+				},
+			},
+			password: "plain-secret",
+		},
+		{
+			setting: DatabaseSetting{
+				PasswordFile: passwordFile,
+			},
+			password: "file-secret",
+		},
+		{
+			setting: DatabaseSetting{
+				PasswordEnv: "CDBM_TEST_PASSWORD",
+			},
+			password: "env-secret",
+		},
+		{
+			setting: DatabaseSetting{
+				PasswordCommand: "echo command-secret",
+			},
+			password: "command-secret",
+		},
+	}
+
+	for _, valid := range validSettings {
+		setting, err := resolvePassword(valid.setting)
+
+		if err != nil {
+			t.Fatalf("should not have error; got %+v", err)
+		}
+
+		if setting.Password != valid.password {
+			t.Errorf("should have password %s; got %s\n", valid.password, setting.Password)
+		}
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that invalid sources return errors
+	invalidSettings := []DatabaseSetting{
+		{
+			PasswordFile: passwordFile,
+			PasswordEnv:  "CDBM_TEST_PASSWORD",
+		},
+		{
+			PasswordFile: filepath.Join(dir, "missing"),
+		},
+		{
+			PasswordEnv: "CDBM_TEST_MISSING_PASSWORD",
+		},
+		{
+			PasswordCommand: "exit 1",
+		},
+	}
+
+	for _, setting := range invalidSettings {
+		if _, err = resolvePassword(setting); err == nil {
+			t.Errorf("should have error for setting %+v\n", setting)
+		}
+	}
+}
+
+func TestRedactErr(t *testing.T) {
+	c := &CDBM{
+		passwords: []string{"secret"},
+	}
+
+	err := c.redactErr(errors.New("failed to connect to user:secret@localhost"))
+
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("should have redacted password; got %s\n", err)
+	}
+
+	origErr := errors.New("connection refused")
+
+	if c.redactErr(origErr) != origErr {
+		t.Errorf("should return original error if nothing was redacted")
+	}
+
+	if c.redactErr(nil) != nil {
+		t.Errorf("should return nil error")
+	}
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	// Registers "postgres" driver opened by openDB
+	_ "github.com/lib/pq"
+)
+
+const (
+	// DefaultApplicationName is application_name session parameter used
+	// if not set so migration sessions can be identified
+	DefaultApplicationName = "cdbm"
+)
+
+// SessionConfig is config struct for connection pool and session settings
+// of connections used by cdbm
+type SessionConfig struct {
+	// MaxOpenConns is max number of open connections to database
+	//
+	// If 0, there is no limit
+	MaxOpenConns int `yaml:"max_open_conns" mapstructure:"max_open_conns"`
+
+	// MaxIdleConns is max number of idle connections kept in pool
+	//
+	// If 0, default of database/sql package is used
+	MaxIdleConns int `yaml:"max_idle_conns" mapstructure:"max_idle_conns"`
+
+	// ConnMaxLifetime is max amount of time a connection can be reused
+	//
+	// If 0, connections are reused forever
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" mapstructure:"conn_max_lifetime"`
+
+	// Params are session parameters set on every new connection
+	// ie. search_path, statement_timeout
+	//
+	// application_name defaults to DefaultApplicationName
+	Params map[string]string `yaml:"params" mapstructure:"params"`
+}
+
+// sessionParams returns session parameters of config with defaults applied
+func (s SessionConfig) sessionParams() map[string]string {
+	params := map[string]string{
+		"application_name": DefaultApplicationName,
+	}
+
+	for k, v := range s.Params {
+		params[k] = v
+	}
+
+	return params
+}
+
+// openDB opens connection pool to database with given setting and applies
+// pool settings of given session config
+//
+// Session parameters are passed in connection string so they are set by server
+// at connection startup without extra round trip
+//
+// If DatabaseSetting#SSLMode is not set, sslmode is "require" if
+// DatabaseSetting#SSL is set, else "disable"
+func openDB(setting webutil.DatabaseSetting, dbType string, session SessionConfig) (*sqlx.DB, error) {
+	db, err := sqlx.Open(dbType, connString(setting, session.sessionParams()))
+
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if session.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(session.MaxOpenConns)
+	}
+	if session.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(session.MaxIdleConns)
+	}
+	if session.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(session.ConnMaxLifetime)
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, errors.WithStack(err)
+	}
+
+	return db, nil
+}
+
+// connString returns key/value connection string of given setting with
+// application_name and rest of given session params passed as "-c" options
+func connString(setting webutil.DatabaseSetting, params map[string]string) string {
+	sslMode := setting.SSLMode
+
+	if sslMode == "" {
+		if setting.SSL {
+			sslMode = "require"
+		} else {
+			sslMode = "disable"
+		}
+	}
+
+	keyValues := [][2]string{
+		{"host", setting.Host},
+		{"port", strconv.Itoa(setting.Port)},
+		{"user", setting.User},
+		{"password", setting.Password},
+		{"dbname", setting.DBName},
+		{"sslmode", sslMode},
+		{"sslrootcert", setting.SSLRootCert},
+		{"sslcert", setting.SSLCert},
+		{"sslkey", setting.SSLKey},
+		{"application_name", params["application_name"]},
+		{"options", sessionOptions(params)},
+	}
+
+	pairs := make([]string, 0, len(keyValues))
+
+	for _, param := range keyValues {
+		if param[1] == "" || (param[0] == "port" && setting.Port == 0) {
+			continue
+		}
+
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(param[1])
+		pairs = append(pairs, param[0]+"='"+value+"'")
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// sessionOptions returns "options" connection parameter setting every given
+// session param except application_name, which has its own parameter
+//
+// Spaces and backslashes within values are escaped with backslash as server
+// splits options on whitespace
+func sessionOptions(params map[string]string) string {
+	names := make([]string, 0, len(params))
+
+	for name := range params {
+		if name != "application_name" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	options := make([]string, 0, len(names))
+	escaper := strings.NewReplacer(`\`, `\\`, " ", `\ `)
+
+	for _, name := range names {
+		options = append(options, "-c "+escaper.Replace(name+"="+params[name]))
+	}
+
+	return strings.Join(options, " ")
+}
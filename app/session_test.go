@@ -0,0 +1,68 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/TravisS25/webutil/webutil"
+)
+
+func TestConnString(t *testing.T) {
+	setting := webutil.DatabaseSetting{
+		BaseAuthSetting: webutil.BaseAuthSetting{
+			Host:     "localhost",
+			Port:     5432,
+			User:     "postgres",
+			Password: `it's\secret`,
+		},
+		DBName: "app",
+	}
+
+	// Validating that values are quoted and sslmode defaults to disable
+	if s := connString(setting, nil); s != `host='localhost' port='5432' user='postgres' password='it\'s\\secret' dbname='app' sslmode='disable'` {
+		t.Errorf("invalid connection string; got %s\n", s)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that sslmode defaults to require with ssl set
+	setting.Password = ""
+	setting.Port = 0
+	setting.SSL = true
+
+	if s := connString(setting, nil); s != `host='localhost' user='postgres' dbname='app' sslmode='require'` {
+		t.Errorf("invalid connection string; got %s\n", s)
+	}
+}
+
+func TestSessionOptions(t *testing.T) {
+	setting := webutil.DatabaseSetting{
+		BaseAuthSetting: webutil.BaseAuthSetting{
+			Host: "localhost",
+		},
+	}
+
+	// Validating that application_name is set by default
+	if s := connString(setting, SessionConfig{}.sessionParams()); s != `host='localhost' sslmode='disable' application_name='cdbm'` {
+		t.Errorf("invalid connection string; got %s\n", s)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that configured params override defaults and are passed as
+	// escaped options
+	params := SessionConfig{
+		Params: map[string]string{
+			"application_name":  "deploy",
+			"statement_timeout": "5min",
+			"search_path":       `app, "my schema"`,
+		},
+	}.sessionParams()
+
+	if s := sessionOptions(params); s != `-c search_path=app,\ "my\ schema" -c statement_timeout=5min` {
+		t.Errorf("invalid options; got %s\n", s)
+	}
+
+	if s := connString(setting, params); s != `host='localhost' sslmode='disable' application_name='deploy' options='-c search_path=app,\\ "my\\ schema" -c statement_timeout=5min'` {
+		t.Errorf("invalid connection string; got %s\n", s)
+	}
+}
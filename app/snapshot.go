@@ -0,0 +1,606 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// snapshotHeader is first line of snapshot written in sql format
+const snapshotHeader = "-- Schema snapshot generated by cdbm.  Do not edit."
+
+// snapshotSchemaFilter leaves out system schemas from snapshot queries
+const snapshotSchemaFilter = "not in ('pg_catalog', 'information_schema', 'pg_toast', 'crdb_internal')"
+
+// snapshotExtensionFilter returns condition leaving out objects of given
+// catalog and oid that are members of an extension, ie. functions created by
+// "create extension", as they are not part of schema managed by migrations
+func snapshotExtensionFilter(catalog, oid string) string {
+	return `not exists (
+				select
+					1
+				from
+					pg_depend
+				where
+					pg_depend.classid = '` + catalog + `'::regclass
+				and
+					pg_depend.objid = ` + oid + `
+				and
+					pg_depend.deptype = 'e'
+			)`
+}
+
+// snapshotSkipTables are tables managed by cdbm that are not part of snapshot
+var snapshotSkipTables = func() map[string]bool {
+	tables := make(map[string]bool, len(cdbmTables))
+
+	for _, table := range cdbmTables {
+		tables[table] = true
+	}
+
+	return tables
+}()
+
+// constraintTypes maps pg_constraint#contype to name of constraint type
+var constraintTypes = map[string]string{
+	"c": "check",
+	"f": "foreign key",
+	"p": "primary key",
+	"u": "unique",
+	"t": "trigger",
+	"x": "exclusion",
+}
+
+// Snapshot is normalized schema of database used to review schema changes
+// and check for drift
+//
+// Every entry is sorted by schema and name, and columns by position, so
+// snapshots of same schema are always equal
+type Snapshot struct {
+	Tables    []SnapshotTable    `json:"tables"`
+	Views     []SnapshotView     `json:"views"`
+	Functions []SnapshotFunction `json:"functions"`
+}
+
+// SnapshotTable is table of Snapshot
+type SnapshotTable struct {
+	Schema      string               `json:"schema"`
+	Name        string               `json:"name"`
+	Columns     []SnapshotColumn     `json:"columns"`
+	Constraints []SnapshotConstraint `json:"constraints"`
+	Indexes     []SnapshotIndex      `json:"indexes"`
+}
+
+// SnapshotColumn is column of SnapshotTable
+type SnapshotColumn struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+	Default  string `json:"default,omitempty"`
+}
+
+// definition returns type of column along with whether it's nullable and
+// its default if any ie. "bigint NOT NULL DEFAULT 0"
+func (c SnapshotColumn) definition() string {
+	def := c.Type
+
+	if !c.Nullable {
+		def += " NOT NULL"
+	}
+	if c.Default != "" {
+		def += " DEFAULT " + c.Default
+	}
+
+	return def
+}
+
+// SnapshotConstraint is constraint of SnapshotTable
+type SnapshotConstraint struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Definition string `json:"definition"`
+}
+
+// SnapshotIndex is index of SnapshotTable
+type SnapshotIndex struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// SnapshotView is view of Snapshot
+type SnapshotView struct {
+	Schema     string `json:"schema"`
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// SnapshotFunction is function of Snapshot
+type SnapshotFunction struct {
+	Schema    string `json:"schema"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Result    string `json:"result"`
+	Language  string `json:"language"`
+	Body      string `json:"body"`
+}
+
+// Snapshot returns normalized schema of CDBM#DB
+func (cdbm *CDBM) Snapshot() (Snapshot, error) {
+	if cdbm.DB == nil {
+		return Snapshot{}, fmt.Errorf("no connection to database was established")
+	}
+
+	return takeSnapshot(cdbm.DB)
+}
+
+// WriteSnapshot writes normalized schema of CDBM#DB to given path as json if
+// path ends with ".json", else as sql
+func (cdbm *CDBM) WriteSnapshot(path string) error {
+	snapshot, err := cdbm.Snapshot()
+
+	if err != nil {
+		return err
+	}
+
+	if err = snapshot.WriteFile(path); err != nil {
+		return err
+	}
+
+	cdbm.logger().Info("wrote schema snapshot", "file", path, "tables", len(snapshot.Tables))
+	return nil
+}
+
+// WriteFile writes snapshot to given path as json if path ends with ".json",
+// else as sql, creating its directory if it doesn't exist
+func (s Snapshot) WriteFile(path string) error {
+	var b []byte
+	var err error
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if b, err = json.MarshalIndent(s, "", "  "); err != nil {
+			return errors.WithStack(err)
+		}
+
+		b = append(b, '\n')
+	} else {
+		b = []byte(s.SQL())
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(ioutil.WriteFile(path, b, 0644))
+}
+
+// SQL returns snapshot as sql statements
+//
+// Statements are ordered as they would have to be run, ie. foreign keys are
+// added after every table is created and functions are created before views
+// that may call them, though schemas, sequences and extensions are not part of
+// snapshot so it's meant for reviewing schema changes, not recreating database
+func (s Snapshot) SQL() string {
+	var sb strings.Builder
+
+	sb.WriteString(snapshotHeader + "\n")
+
+	foreignKeys := make([]string, 0)
+
+	for _, table := range s.Tables {
+		name := table.Schema + "." + table.Name
+		columns := make([]string, 0, len(table.Columns))
+
+		for _, column := range table.Columns {
+			columns = append(columns, "\t"+column.Name+" "+column.definition())
+		}
+
+		sb.WriteString(fmt.Sprintf("\nCREATE TABLE %s (\n%s\n);\n", name, strings.Join(columns, ",\n")))
+
+		for _, constraint := range table.Constraints {
+			stmt := fmt.Sprintf(
+				"ALTER TABLE %s ADD CONSTRAINT %s %s;\n",
+				name,
+				constraint.Name,
+				constraint.Definition,
+			)
+
+			if constraint.Type == constraintTypes["f"] {
+				foreignKeys = append(foreignKeys, stmt)
+				continue
+			}
+
+			sb.WriteString(stmt)
+		}
+
+		for _, index := range table.Indexes {
+			sb.WriteString(strings.TrimSuffix(index.Definition, ";") + ";\n")
+		}
+	}
+
+	if len(foreignKeys) > 0 {
+		sb.WriteString("\n" + strings.Join(foreignKeys, ""))
+	}
+
+	for _, function := range s.Functions {
+		quote := dollarQuote(function.Body)
+
+		sb.WriteString(fmt.Sprintf(
+			"\nCREATE FUNCTION %s.%s(%s) RETURNS %s LANGUAGE %s AS %s\n%s\n%s;\n",
+			function.Schema,
+			function.Name,
+			function.Arguments,
+			function.Result,
+			function.Language,
+			quote,
+			function.Body,
+			quote,
+		))
+	}
+
+	for _, view := range s.Views {
+		sb.WriteString(fmt.Sprintf(
+			"\nCREATE VIEW %s.%s AS\n%s;\n",
+			view.Schema,
+			view.Name,
+			strings.TrimSuffix(view.Definition, ";"),
+		))
+	}
+
+	return sb.String()
+}
+
+// dollarQuote returns dollar quote that doesn't appear in given function body
+// so body is never cut short ie. "$$", else "$cdbm$", "$cdbm1$" and so on
+func dollarQuote(body string) string {
+	quote := "$$"
+
+	for i := 0; strings.Contains(body, quote); i++ {
+		if i == 0 {
+			quote = "$cdbm$"
+		} else {
+			quote = "$cdbm" + strconv.Itoa(i) + "$"
+		}
+	}
+
+	return quote
+}
+
+// takeSnapshot queries pg_catalog of given database for its normalized schema
+//
+// System schemas and tables managed by cdbm are left out
+func takeSnapshot(db *sqlx.DB) (Snapshot, error) {
+	var err error
+
+	snapshot := Snapshot{}
+
+	if snapshot.Tables, err = snapshotTables(db); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.Views, err = snapshotViews(db); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.Functions, err = snapshotFunctions(db); err != nil {
+		return Snapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+// snapshotTables queries tables of given database along with their columns,
+// constraints and indexes
+func snapshotTables(db *sqlx.DB) ([]SnapshotTable, error) {
+	rows, err := db.Query(
+		`
+		select
+			pg_namespace.nspname,
+			pg_class.relname,
+			pg_attribute.attname,
+			format_type(pg_attribute.atttypid, pg_attribute.atttypmod),
+			pg_attribute.attnotnull,
+			coalesce(pg_get_expr(pg_attrdef.adbin, pg_attrdef.adrelid), '')
+		from
+			pg_attribute
+		join
+			pg_class on pg_class.oid = pg_attribute.attrelid
+		join
+			pg_namespace on pg_namespace.oid = pg_class.relnamespace
+		left join
+			pg_attrdef on pg_attrdef.adrelid = pg_attribute.attrelid
+			and pg_attrdef.adnum = pg_attribute.attnum
+		where
+			pg_class.relkind in ('r', 'p')
+		and
+			pg_attribute.attnum > 0
+		and
+			not pg_attribute.attisdropped
+		and
+			pg_namespace.nspname ` + snapshotSchemaFilter + `
+		and
+			` + snapshotExtensionFilter("pg_class", "pg_class.oid") + `
+		order by
+			pg_namespace.nspname, pg_class.relname, pg_attribute.attnum
+		`,
+	)
+
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	defer rows.Close()
+
+	tables := make([]SnapshotTable, 0)
+	tableIdx := make(map[string]int)
+
+	for rows.Next() {
+		var schema, table string
+		var notNull bool
+		var column SnapshotColumn
+
+		if err = rows.Scan(&schema, &table, &column.Name, &column.Type, &notNull, &column.Default); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if snapshotSkipTables[table] {
+			continue
+		}
+
+		i, ok := tableIdx[schema+"."+table]
+
+		if !ok {
+			i = len(tables)
+			tableIdx[schema+"."+table] = i
+			tables = append(tables, SnapshotTable{
+				Schema:      schema,
+				Name:        table,
+				Columns:     make([]SnapshotColumn, 0),
+				Constraints: make([]SnapshotConstraint, 0),
+				Indexes:     make([]SnapshotIndex, 0),
+			})
+		}
+
+		column.Nullable = !notNull
+		tables[i].Columns = append(tables[i].Columns, column)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err = snapshotConstraints(db, tables, tableIdx); err != nil {
+		return nil, err
+	}
+
+	if err = snapshotIndexes(db, tables, tableIdx); err != nil {
+		return nil, err
+	}
+
+	return tables, nil
+}
+
+// snapshotConstraints queries constraints of given database and adds them to
+// their table of given tables which are indexed by "schema.table"
+func snapshotConstraints(db *sqlx.DB, tables []SnapshotTable, tableIdx map[string]int) error {
+	rows, err := db.Query(
+		`
+		select
+			pg_namespace.nspname,
+			pg_class.relname,
+			pg_constraint.conname,
+			pg_constraint.contype::text,
+			pg_get_constraintdef(pg_constraint.oid)
+		from
+			pg_constraint
+		join
+			pg_class on pg_class.oid = pg_constraint.conrelid
+		join
+			pg_namespace on pg_namespace.oid = pg_class.relnamespace
+		where
+			pg_namespace.nspname ` + snapshotSchemaFilter + `
+		order by
+			pg_namespace.nspname, pg_class.relname, pg_constraint.conname
+		`,
+	)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var schema, table string
+		var constraint SnapshotConstraint
+
+		if err = rows.Scan(&schema, &table, &constraint.Name, &constraint.Type, &constraint.Definition); err != nil {
+			return errors.WithStack(err)
+		}
+
+		if i, ok := tableIdx[schema+"."+table]; ok {
+			if name, ok := constraintTypes[constraint.Type]; ok {
+				constraint.Type = name
+			}
+
+			tables[i].Constraints = append(tables[i].Constraints, constraint)
+		}
+	}
+
+	return errors.WithStack(rows.Err())
+}
+
+// snapshotIndexes queries indexes of given database and adds them to their
+// table of given tables which are indexed by "schema.table"
+//
+// Indexes backing primary key, unique and exclusion constraints are left out
+// as they are created by their constraint
+func snapshotIndexes(db *sqlx.DB, tables []SnapshotTable, tableIdx map[string]int) error {
+	rows, err := db.Query(
+		`
+		select
+			schemaname,
+			tablename,
+			indexname,
+			indexdef
+		from
+			pg_indexes
+		where
+			schemaname ` + snapshotSchemaFilter + `
+		and
+			not exists (
+				select
+					1
+				from
+					pg_constraint
+				where
+					pg_constraint.conindid = format('%I.%I', schemaname, indexname)::regclass
+				and
+					pg_constraint.contype in ('p', 'u', 'x')
+			)
+		order by
+			schemaname, tablename, indexname
+		`,
+	)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var schema, table string
+		var index SnapshotIndex
+
+		if err = rows.Scan(&schema, &table, &index.Name, &index.Definition); err != nil {
+			return errors.WithStack(err)
+		}
+
+		if i, ok := tableIdx[schema+"."+table]; ok {
+			index.Definition = normalizeDefinition(index.Definition)
+			tables[i].Indexes = append(tables[i].Indexes, index)
+		}
+	}
+
+	return errors.WithStack(rows.Err())
+}
+
+// snapshotViews queries views of given database
+func snapshotViews(db *sqlx.DB) ([]SnapshotView, error) {
+	rows, err := db.Query(
+		`
+		select
+			schemaname,
+			viewname,
+			definition
+		from
+			pg_views
+		where
+			schemaname ` + snapshotSchemaFilter + `
+		and
+			` + snapshotExtensionFilter("pg_class", "format('%I.%I', schemaname, viewname)::regclass") + `
+		order by
+			schemaname, viewname
+		`,
+	)
+
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	defer rows.Close()
+
+	views := make([]SnapshotView, 0)
+
+	for rows.Next() {
+		var view SnapshotView
+
+		if err = rows.Scan(&view.Schema, &view.Name, &view.Definition); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		view.Definition = normalizeDefinition(view.Definition)
+		views = append(views, view)
+	}
+
+	return views, errors.WithStack(rows.Err())
+}
+
+// snapshotFunctions queries functions of given database
+//
+// Only plain functions are queried as procedures, aggregates and window
+// functions can't be created with "CREATE FUNCTION" of Snapshot#SQL
+func snapshotFunctions(db *sqlx.DB) ([]SnapshotFunction, error) {
+	rows, err := db.Query(
+		`
+		select
+			pg_namespace.nspname,
+			pg_proc.proname,
+			pg_get_function_identity_arguments(pg_proc.oid),
+			coalesce(pg_get_function_result(pg_proc.oid), ''),
+			pg_language.lanname,
+			pg_proc.prosrc
+		from
+			pg_proc
+		join
+			pg_namespace on pg_namespace.oid = pg_proc.pronamespace
+		join
+			pg_language on pg_language.oid = pg_proc.prolang
+		where
+			pg_namespace.nspname ` + snapshotSchemaFilter + `
+		and
+			pg_proc.prokind = 'f'
+		and
+			` + snapshotExtensionFilter("pg_proc", "pg_proc.oid") + `
+		order by
+			pg_namespace.nspname,
+			pg_proc.proname,
+			pg_get_function_identity_arguments(pg_proc.oid)
+		`,
+	)
+
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	defer rows.Close()
+
+	functions := make([]SnapshotFunction, 0)
+
+	for rows.Next() {
+		var function SnapshotFunction
+
+		if err = rows.Scan(
+			&function.Schema,
+			&function.Name,
+			&function.Arguments,
+			&function.Result,
+			&function.Language,
+			&function.Body,
+		); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		function.Body = normalizeDefinition(function.Body)
+		functions = append(functions, function)
+	}
+
+	return functions, errors.WithStack(rows.Err())
+}
+
+// normalizeDefinition trims surrounding whitespace and trailing whitespace of
+// every line of given definition so it doesn't cause noise in diffs
+func normalizeDefinition(def string) string {
+	lines := strings.Split(strings.TrimSpace(def), "\n")
+
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,272 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+)
+
+// expectSnapshot sets given mock to return rows of snapshot queries of
+// a users table with an index, constraint and view along with a function
+func expectSnapshot(mockDB sqlmock.Sqlmock) {
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"nspname", "relname", "attname", "format_type", "attnotnull", "default"}).
+			AddRow("public", "schema_migrations", "version", "bigint", true, "").
+			AddRow("public", "users", "id", "bigint", true, "nextval('users_id_seq'::regclass)").
+			AddRow("public", "users", "email", "character varying(255)", false, ""),
+	)
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"nspname", "relname", "conname", "contype", "def"}).
+			AddRow("public", "schema_migrations", "schema_migrations_pkey", "p", "PRIMARY KEY (version)").
+			AddRow("public", "users", "users_pkey", "p", "PRIMARY KEY (id)"),
+	)
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"schemaname", "tablename", "indexname", "indexdef"}).
+			AddRow("public", "users", "users_email_idx", "CREATE INDEX users_email_idx ON public.users USING btree (email)  "),
+	)
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"schemaname", "viewname", "definition"}).
+			AddRow("public", "user_emails", " SELECT users.email   \n   FROM users;"),
+	)
+	mockDB.ExpectQuery("").WillReturnRows(
+		mockDB.NewRows([]string{"nspname", "proname", "args", "result", "lanname", "prosrc"}).
+			AddRow("public", "add", "a integer, b integer", "integer", "sql", "\n select a + b \n"),
+	)
+}
+
+func TestSnapshot(t *testing.T) {
+	var err error
+
+	dir, err := ioutil.TempDir("", "cdbm-snapshot")
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	if _, err = (&CDBM{}).Snapshot(); err == nil {
+		t.Errorf("should have error without database")
+	}
+
+	db, mockDB, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	c := &CDBM{
+		DB: sqlx.NewDb(db, webutil.Postgres),
+	}
+
+	// Validating that snapshot is normalized and leaves out tables of cdbm
+	expectSnapshot(mockDB)
+
+	snapshot, err := c.Snapshot()
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	expected := Snapshot{
+		Tables: []SnapshotTable{
+			{
+				Schema: "public",
+				Name:   "users",
+				Columns: []SnapshotColumn{
+					{Name: "id", Type: "bigint", Default: "nextval('users_id_seq'::regclass)"},
+					{Name: "email", Type: "character varying(255)", Nullable: true},
+				},
+				Constraints: []SnapshotConstraint{
+					{Name: "users_pkey", Type: "primary key", Definition: "PRIMARY KEY (id)"},
+				},
+				Indexes: []SnapshotIndex{
+					{Name: "users_email_idx", Definition: "CREATE INDEX users_email_idx ON public.users USING btree (email)"},
+				},
+			},
+		},
+		Views: []SnapshotView{
+			{Schema: "public", Name: "user_emails", Definition: "SELECT users.email\n   FROM users;"},
+		},
+		Functions: []SnapshotFunction{
+			{Schema: "public", Name: "add", Arguments: "a integer, b integer", Result: "integer", Language: "sql", Body: "select a + b"},
+		},
+	}
+
+	if !reflect.DeepEqual(snapshot, expected) {
+		t.Errorf("should have snapshot %+v; got %+v", expected, snapshot)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that snapshot is written as sql or json based on extension
+	sqlPath := filepath.Join(dir, "db", "schema.sql")
+
+	if err = snapshot.WriteFile(sqlPath); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	b, _ := ioutil.ReadFile(sqlPath)
+
+	for _, stmt := range []string{
+		snapshotHeader,
+		"CREATE TABLE public.users (\n\tid bigint NOT NULL DEFAULT nextval('users_id_seq'::regclass),\n\temail character varying(255)\n);",
+		"ALTER TABLE public.users ADD CONSTRAINT users_pkey PRIMARY KEY (id);",
+		"CREATE INDEX users_email_idx ON public.users USING btree (email);",
+		"CREATE VIEW public.user_emails AS\nSELECT users.email\n   FROM users;",
+		"CREATE FUNCTION public.add(a integer, b integer) RETURNS integer LANGUAGE sql AS $$\nselect a + b\n$$;",
+	} {
+		if !strings.Contains(string(b), stmt) {
+			t.Errorf("sql snapshot should contain %q; got %s", stmt, string(b))
+		}
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that foreign keys are added after every table is created and
+	// functions are created before views
+	fkSnapshot := Snapshot{
+		Tables: []SnapshotTable{
+			{
+				Schema: "public",
+				Name:   "orders",
+				Columns: []SnapshotColumn{
+					{Name: "user_id", Type: "bigint"},
+				},
+				Constraints: []SnapshotConstraint{
+					{Name: "orders_user_id_fkey", Type: "foreign key", Definition: "FOREIGN KEY (user_id) REFERENCES public.users(id)"},
+				},
+			},
+			expected.Tables[0],
+		},
+		Views:     expected.Views,
+		Functions: expected.Functions,
+	}
+
+	fkSQL := fkSnapshot.SQL()
+	fkIdx := strings.Index(fkSQL, "ALTER TABLE public.orders ADD CONSTRAINT orders_user_id_fkey")
+
+	if fkIdx < strings.Index(fkSQL, "CREATE TABLE public.users") {
+		t.Errorf("foreign key should be added after referenced table; got %s", fkSQL)
+	}
+	if strings.Index(fkSQL, "CREATE VIEW") < strings.Index(fkSQL, "CREATE FUNCTION") {
+		t.Errorf("view should be created after function; got %s", fkSQL)
+	}
+
+	jsonPath := filepath.Join(dir, "schema.json")
+
+	if err = snapshot.WriteFile(jsonPath); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	var jsonSnapshot Snapshot
+
+	b, _ = ioutil.ReadFile(jsonPath)
+
+	if err = json.Unmarshal(b, &jsonSnapshot); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+	if !reflect.DeepEqual(jsonSnapshot, expected) {
+		t.Errorf("should have json snapshot %+v; got %+v", expected, jsonSnapshot)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that query error is returned
+	queryErr := errors.New("query error")
+	mockDB.ExpectQuery("").WillReturnError(queryErr)
+
+	if err = c.WriteSnapshot(sqlPath); err == nil {
+		t.Errorf("should have error")
+	} else if !errors.Is(err, queryErr) {
+		t.Errorf("should have %s; got %s", queryErr, err)
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf("should not have error; got %+v", err)
+	}
+}
+
+func TestSnapshotViewsAndFunctions(t *testing.T) {
+	var err error
+
+	db, mockDB, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	sqlxDB := sqlx.NewDb(db, webutil.Postgres)
+
+	// Validating that views query leaves out views of extensions
+	mockDB.ExpectQuery(`(?s)from\s+pg_views.*pg_depend\.objid = format\('%I\.%I', schemaname, viewname\)::regclass.*pg_depend\.deptype = 'e'`).
+		WillReturnRows(
+			mockDB.NewRows([]string{"schemaname", "viewname", "definition"}).
+				AddRow("public", "user_emails", " SELECT users.email\n   FROM users;"),
+		)
+
+	views, err := snapshotViews(sqlxDB)
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	expectedViews := []SnapshotView{
+		{Schema: "public", Name: "user_emails", Definition: "SELECT users.email\n   FROM users;"},
+	}
+
+	if !reflect.DeepEqual(views, expectedViews) {
+		t.Errorf("should have views %+v; got %+v", expectedViews, views)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that functions query leaves out functions of extensions and
+	// anything but plain functions
+	mockDB.ExpectQuery(`(?s)from\s+pg_proc.*pg_proc\.prokind = 'f'.*pg_depend\.objid = pg_proc\.oid.*pg_depend\.deptype = 'e'`).
+		WillReturnRows(
+			mockDB.NewRows([]string{"nspname", "proname", "args", "result", "lanname", "prosrc"}).
+				AddRow("public", "quote", "", "text", "sql", "select $$it's$$::text"),
+		)
+
+	functions, err := snapshotFunctions(sqlxDB)
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	expectedFunctions := []SnapshotFunction{
+		{Schema: "public", Name: "quote", Result: "text", Language: "sql", Body: "select $$it's$$::text"},
+	}
+
+	if !reflect.DeepEqual(functions, expectedFunctions) {
+		t.Errorf("should have functions %+v; got %+v", expectedFunctions, functions)
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf("should not have error; got %+v", err)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that function body containing "$$" is quoted with different
+	// dollar quote
+	stmt := "CREATE FUNCTION public.quote() RETURNS text LANGUAGE sql AS $cdbm$\nselect $$it's$$::text\n$cdbm$;"
+
+	if s := (Snapshot{Functions: functions}).SQL(); !strings.Contains(s, stmt) {
+		t.Errorf("sql snapshot should contain %q; got %s", stmt, s)
+	}
+
+	if q := dollarQuote("select '$$' || '$cdbm$'"); q != "$cdbm1$" {
+		t.Errorf("should have dollar quote $cdbm1$; got %s", q)
+	}
+}
@@ -0,0 +1,360 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// archiveDir is directory within migrations directory that squashed
+	// migration files are moved to
+	archiveDir = "archive"
+
+	// baselineName is name used for baseline file of squashed migrations
+	// ie. "000010_baseline.up.sql"
+	baselineName = "baseline"
+)
+
+// rename is used to move files when squashing and is a var so tests can replace it
+var rename = os.Rename
+
+// Squash squashes every migration up to and including given version into a
+// single baseline up file
+//
+// Scratch database is created with cdbmutil.GetNewDatabase, migrated to given
+// version and its schema is dumped with DBAction#DumpSchema of cdbmutil config
+// into baseline file with "-- cdbm:baseline" header.  Squashed migration files
+// are moved to "archive" directory within migrations directory
+//
+// Baseline file has same version as last squashed migration so databases
+// already at or past that version continue unaffected while fresh databases
+// start from baseline.  Custom migrations that are squashed must be removed
+// from code by user
+func (cdbm *CDBM) Squash(
+	through int,
+	getMigFunc cdbmutil.GetMigrationFunc,
+	fMigFunc cdbmutil.FileMigrationFunc,
+	cMigrations map[int]cdbmutil.CustomMigration,
+) error {
+	var err error
+
+	if err = cdbm.checkMigrationsProtocol(); err != nil {
+		return err
+	}
+
+	if cdbm.MigrateFlags.MigrationsProtocol != cdbmutil.FileProtocol {
+		return fmt.Errorf("squash requires --migrations-protocol %s", cdbmutil.FileProtocol)
+	}
+
+	cdbm.migrateCfg.CustomMigrations = cMigrations
+	cfgs, err := cdbm.verifyFilesAndMigrations()
+
+	if err != nil {
+		return err
+	}
+
+	var throughCfg *migrationApplyConfig
+
+	for i := range cfgs {
+		if cfgs[i].Version == through {
+			throughCfg = &cfgs[i]
+		}
+	}
+
+	if throughCfg == nil {
+		return fmt.Errorf("--through version %d does not exist", through)
+	}
+
+	if baseline := baselineVersion(cfgs); through <= baseline {
+		return fmt.Errorf("--through version %d must be greater than current baseline version %d", through, baseline)
+	}
+
+	scratch, settings, dropScratch, err := cdbm.newScratch()
+
+	if err != nil {
+		return err
+	}
+
+	defer dropScratch()
+
+	if settings.DBAction.DumpSchema == "" {
+		return fmt.Errorf("dump_schema command of cdbmutil config required to squash migrations")
+	}
+
+	if err = scratch.migrateScratch(through, getMigFunc, fMigFunc, cMigrations); err != nil {
+		return err
+	}
+
+	// Tables of cdbm are created by migrating so they are left out of baseline
+	if _, err = scratch.DB.Exec(
+		"drop table if exists " + strings.Join(cdbmTables, ", ") + ";",
+	); err != nil {
+		return errors.WithStack(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	dumpCmd := exec.Command(
+		"/bin/sh",
+		"-c",
+		fmt.Sprintf(settings.DBAction.DumpSchema, scratch.currentDBSettings.DBName),
+	)
+	dumpCmd.Stdout = &stdout
+	dumpCmd.Stderr = &stderr
+
+	if err = dumpCmd.Run(); err != nil {
+		return fmt.Errorf("can't dump schema of scratch database: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	prefix := strconv.Itoa(through)
+
+	if throughCfg.UpFile != "" {
+		prefix = strings.Split(filepath.Base(throughCfg.UpFile), "_")[0]
+	}
+
+	baselineFile, archived, err := squashFiles(
+		cdbm.MigrateFlags.MigrationsDir,
+		through,
+		prefix,
+		baselineDump(stdout.Bytes()),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	cdbm.logger().Info(
+		"squashed migrations",
+		"through", through,
+		"baseline", baselineFile,
+		"archived", len(archived),
+	)
+
+	fmt.Printf("Squashed versions through %d into %s\n", through, baselineFile)
+	fmt.Printf("Archived %d file(s) to %s\n", len(archived), filepath.Join(cdbm.MigrateFlags.MigrationsDir, archiveDir))
+
+	custom := make([]int, 0)
+
+	for _, cfg := range cfgs {
+		if cfg.Version <= through && cfg.isCustomMigration() {
+			custom = append(custom, cfg.Version)
+		}
+	}
+
+	if len(custom) > 0 {
+		fmt.Printf("Custom migrations %v are part of baseline and must be removed from code\n", custom)
+	}
+
+	return nil
+}
+
+// squashFiles moves every migration file in given directory with version lower
+// than or equal to given version into archive directory and writes baseline
+// file with given prefix and schema dump
+//
+// Baseline is written to temp file before any file is moved and moved files
+// are moved back if anything fails so migrations directory is left as it was
+//
+// Returns path of baseline file and names of archived files
+func squashFiles(migrationsDir string, through int, prefix string, dump []byte) (string, []string, error) {
+	files, err := ioutil.ReadDir(migrationsDir)
+
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	archivePath := filepath.Join(migrationsDir, archiveDir)
+	archived := make([]string, 0)
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		version, err := strconv.Atoi(strings.Split(file.Name(), "_")[0])
+
+		if err != nil || version > through {
+			continue
+		}
+
+		// Make sure nothing is moved if any file would overwrite archived file
+		if _, err = os.Stat(filepath.Join(archivePath, file.Name())); err == nil {
+			return "", nil, fmt.Errorf("file %s already exists in %s", file.Name(), archivePath)
+		}
+
+		archived = append(archived, file.Name())
+	}
+
+	baselineFile := filepath.Join(migrationsDir, fmt.Sprintf("%s_%s.up.sql", prefix, baselineName))
+
+	if _, err = os.Stat(baselineFile); err == nil {
+		return "", nil, fmt.Errorf("baseline file %s already exists", baselineFile)
+	}
+
+	// Temp file doesn't match migration file pattern so it's never picked up
+	// as migration if left behind
+	tmpFile, err := ioutil.TempFile(migrationsDir, "."+baselineName+"-*.tmp")
+
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	defer os.Remove(tmpFile.Name())
+
+	var buf bytes.Buffer
+
+	buf.WriteString("-- " + cdbmutil.BaselineHeader + "\n")
+	buf.WriteString(fmt.Sprintf("-- Baseline of migrations through version %d archived in %s directory\n\n", through, archiveDir))
+	buf.Write(dump)
+
+	if _, err = tmpFile.Write(buf.Bytes()); err == nil {
+		err = tmpFile.Chmod(0644)
+	}
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	if err = os.MkdirAll(archivePath, 0755); err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	moved := make([]string, 0, len(archived))
+
+	// rollback moves archived files back so migrations directory is left as
+	// it was and returns given error
+	rollback := func(err error) error {
+		for _, name := range moved {
+			if renameErr := rename(
+				filepath.Join(archivePath, name),
+				filepath.Join(migrationsDir, name),
+			); renameErr != nil {
+				return fmt.Errorf("%v; can't move %s back from %s: %v", err, name, archivePath, renameErr)
+			}
+		}
+
+		return err
+	}
+
+	for _, name := range archived {
+		if err = rename(
+			filepath.Join(migrationsDir, name),
+			filepath.Join(archivePath, name),
+		); err != nil {
+			return "", nil, rollback(errors.WithStack(err))
+		}
+
+		moved = append(moved, name)
+	}
+
+	if err = rename(tmpFile.Name(), baselineFile); err != nil {
+		return "", nil, rollback(errors.WithStack(err))
+	}
+
+	return baselineFile, archived, nil
+}
+
+// baselineDump returns given pg_dump output without statements that would
+// break migrating with baseline file
+//
+// pg_dump empties search_path with set_config which would leave it empty for
+// rest of session so unqualified statements run on same connection afterwards,
+// ie. golang-migrate updating schema_migrations, would fail.  psql meta commands
+// like "\restrict" of newer pg_dump versions are not valid sql so they are
+// removed as well
+func baselineDump(dump []byte) []byte {
+	lines := strings.SplitAfter(string(dump), "\n")
+	kept := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "SELECT pg_catalog.set_config('search_path'") ||
+			strings.HasPrefix(trimmed, "\\") {
+			continue
+		}
+
+		kept = append(kept, line)
+	}
+
+	return []byte(strings.Join(kept, ""))
+}
+
+// baselineVersion returns highest version of given configs that is baseline
+// of squashed migrations or 0 if there is none
+func baselineVersion(cfgs []migrationApplyConfig) int {
+	version := 0
+
+	for _, cfg := range cfgs {
+		if cfg.Baseline && cfg.Version > version {
+			version = cfg.Version
+		}
+	}
+
+	return version
+}
+
+// checkBaseline returns error if any migration is lower than baseline version
+// or if database has migrations applied but is behind baseline version
+func (cdbm *CDBM) checkBaseline(cfgs []migrationApplyConfig) error {
+	baseline := baselineVersion(cfgs)
+
+	if baseline == 0 {
+		return nil
+	}
+
+	for _, cfg := range cfgs {
+		if cfg.Version < baseline {
+			return fmt.Errorf(
+				"%s migration version %d is lower than baseline version %d.  Squashed migrations must be archived or removed",
+				cfg.kind(),
+				cfg.Version,
+				baseline,
+			)
+		}
+	}
+
+	sm := cdbm.migrateCfg.SchemaMigration
+
+	if !sm.SchemaCfg.NoRows && sm.StartingVersion < baseline {
+		return fmt.Errorf(
+			"database at version %d is behind baseline version %d.  Apply migrations in %s directory up to version %d first",
+			sm.StartingVersion,
+			baseline,
+			archiveDir,
+			baseline,
+		)
+	}
+
+	return nil
+}
+
+// checkBaselineTarget returns error if database would be migrated below
+// baseline version as baseline can't be reversed
+func (cdbm *CDBM) checkBaselineTarget(cfgs []migrationApplyConfig) error {
+	baseline := baselineVersion(cfgs)
+
+	if baseline == 0 || cdbm.migrateCfg.SchemaMigration.SchemaCfg.NoRows {
+		return nil
+	}
+
+	if cdbm.migrateCfg.TargetVersion < baseline {
+		return fmt.Errorf("can't migrate below baseline version %d", baseline)
+	}
+
+	return nil
+}
@@ -0,0 +1,271 @@
+package app
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSquashFiles(t *testing.T) {
+	var err error
+
+	dir, err := ioutil.TempDir("", "cdbm-squash")
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{
+		"000001_init.up.sql",
+		"000001_init.down.sql",
+		"000002_users.up.sql",
+		"000002_users.down.sql",
+		"000003_orders.up.sql",
+		"000003_orders.down.sql",
+	} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte("select 1;"), os.ModePerm); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	// Validating that squashed files are archived and baseline is written
+	baselineFile, archived, err := squashFiles(dir, 2, "000002", []byte("CREATE TABLE users (id INT8);\n"))
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	expectedArchived := []string{
+		"000001_init.down.sql",
+		"000001_init.up.sql",
+		"000002_users.down.sql",
+		"000002_users.up.sql",
+	}
+
+	if !reflect.DeepEqual(archived, expectedArchived) {
+		t.Errorf("should have archived %v; got %v", expectedArchived, archived)
+	}
+
+	for _, name := range expectedArchived {
+		if _, err = os.Stat(filepath.Join(dir, archiveDir, name)); err != nil {
+			t.Errorf("should have archived file %s; got %s", name, err)
+		}
+	}
+
+	if baselineFile != filepath.Join(dir, "000002_baseline.up.sql") {
+		t.Errorf("should have baseline file 000002_baseline.up.sql; got %s", baselineFile)
+	}
+
+	b, _ := ioutil.ReadFile(baselineFile)
+
+	if !strings.HasSuffix(string(b), "CREATE TABLE users (id INT8);\n") {
+		t.Errorf("baseline file should end with schema dump; got %s", string(b))
+	}
+
+	header, err := readFileHeader(baselineFile)
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+	if !header.Baseline {
+		t.Errorf("baseline file should have baseline header")
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "000003_orders.up.sql")); err != nil {
+		t.Errorf("should not have archived version 3; got %s", err)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that nothing is moved if file is already archived
+	if err = ioutil.WriteFile(filepath.Join(dir, archiveDir, "000003_orders.up.sql"), []byte("select 1;"), os.ModePerm); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if _, _, err = squashFiles(dir, 3, "000003", nil); err == nil {
+		t.Errorf("should have error")
+	}
+
+	for _, name := range []string{"000002_baseline.up.sql", "000003_orders.up.sql", "000003_orders.down.sql"} {
+		if _, err = os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("should not have moved file %s; got %s", name, err)
+		}
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that archived files are moved back and no baseline is left
+	// if writing baseline fails
+	defer func() {
+		rename = os.Rename
+	}()
+
+	os.Remove(filepath.Join(dir, archiveDir, "000003_orders.up.sql"))
+
+	rename = func(oldPath, newPath string) error {
+		if strings.HasSuffix(newPath, "000003_baseline.up.sql") {
+			return errors.New("disk full")
+		}
+
+		return os.Rename(oldPath, newPath)
+	}
+
+	if _, _, err = squashFiles(dir, 3, "000003", nil); err == nil {
+		t.Errorf("should have error")
+	}
+
+	for _, name := range []string{"000002_baseline.up.sql", "000003_orders.up.sql", "000003_orders.down.sql"} {
+		if _, err = os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("should have moved file %s back; got %s", name, err)
+		}
+	}
+
+	entries, _ := ioutil.ReadDir(dir)
+
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") || strings.HasPrefix(entry.Name(), "000003_baseline") {
+			t.Errorf("should not have left file %s", entry.Name())
+		}
+	}
+}
+
+func TestCheckBaseline(t *testing.T) {
+	cfgs := []migrationApplyConfig{
+		{Version: 10, Baseline: true},
+		{Version: 11},
+	}
+
+	c := &CDBM{}
+
+	// Validating that fresh database starts from baseline
+	c.migrateCfg.SchemaMigration.SchemaCfg.NoRows = true
+	c.migrateCfg.TargetVersion = 11
+
+	if err := c.checkBaseline(cfgs); err != nil {
+		t.Errorf("should not have error; got %s", err)
+	}
+	if err := c.checkBaselineTarget(cfgs); err != nil {
+		t.Errorf("should not have error; got %s", err)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that database at or past baseline continues unaffected
+	c.migrateCfg.SchemaMigration.SchemaCfg.NoRows = false
+	c.migrateCfg.SchemaMigration.StartingVersion = 10
+
+	if err := c.checkBaseline(cfgs); err != nil {
+		t.Errorf("should not have error; got %s", err)
+	}
+	if err := c.checkBaselineTarget(cfgs); err != nil {
+		t.Errorf("should not have error; got %s", err)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that database can't migrate below baseline
+	c.migrateCfg.TargetVersion = 0
+
+	if err := c.checkBaselineTarget(cfgs); err == nil {
+		t.Errorf("should have error")
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that database behind baseline has error
+	c.migrateCfg.SchemaMigration.StartingVersion = 5
+
+	if err := c.checkBaseline(cfgs); err == nil {
+		t.Errorf("should have error")
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that migration lower than baseline has error
+	c.migrateCfg.SchemaMigration.SchemaCfg.NoRows = true
+
+	if err := c.checkBaseline(append([]migrationApplyConfig{{Version: 3}}, cfgs...)); err == nil {
+		t.Errorf("should have error")
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that migrations without baseline are not checked
+	if err := c.checkBaseline([]migrationApplyConfig{{Version: 3}}); err != nil {
+		t.Errorf("should not have error; got %s", err)
+	}
+}
+
+func TestBaselineDump(t *testing.T) {
+	dump := `--
+-- PostgreSQL database dump
+--
+
+\restrict 3yqv0bXHlOa5EbKlKNhxMvV0YhkmYmRe4fKdUq1xH
+
+-- Dumped from database version 16.10
+-- Dumped by pg_dump version 16.10
+
+SET statement_timeout = 0;
+SET lock_timeout = 0;
+SET client_encoding = 'UTF8';
+SET standard_conforming_strings = on;
+SELECT pg_catalog.set_config('search_path', '', false);
+SET check_function_bodies = false;
+SET client_min_messages = warning;
+SET row_security = off;
+
+SET default_tablespace = '';
+
+SET default_table_access_method = heap;
+
+--
+-- Name: users; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.users (
+    id bigint NOT NULL,
+    email character varying(255)
+);
+
+--
+-- Name: users users_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+--
+-- PostgreSQL database dump complete
+--
+
+\unrestrict 3yqv0bXHlOa5EbKlKNhxMvV0YhkmYmRe4fKdUq1xH
+
+`
+
+	// Validating that search_path reset and psql meta commands are removed
+	// while rest of dump is kept as is
+	b := string(baselineDump([]byte(dump)))
+
+	for _, removed := range []string{"set_config('search_path'", `\restrict`, `\unrestrict`} {
+		if strings.Contains(b, removed) {
+			t.Errorf("baseline dump should not contain %q; got %s", removed, b)
+		}
+	}
+
+	for _, kept := range []string{
+		"SET client_min_messages = warning;\n",
+		"CREATE TABLE public.users (\n    id bigint NOT NULL,\n    email character varying(255)\n);\n",
+		"ALTER TABLE ONLY public.users\n    ADD CONSTRAINT users_pkey PRIMARY KEY (id);\n",
+	} {
+		if !strings.Contains(b, kept) {
+			t.Errorf("baseline dump should contain %q; got %s", kept, b)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TravisS25/webutil/webutil"
+)
+
+// ValidSSLModes are the sslmode values accepted when connecting to database
+var ValidSSLModes = []string{
+	"disable",
+	"allow",
+	"prefer",
+	"require",
+	"verify-ca",
+	"verify-full",
+}
+
+// sslModePrefix is deprecated prefix of sslmode ie. "sslmode=require" that
+// was previously documented for --ssl-mode
+const sslModePrefix = "sslmode="
+
+// applySSL verifies ssl settings of given setting and returns setting with
+// DatabaseSetting#SSL turned on if any ssl setting other than sslmode=disable
+// is used so ssl settings aren't silently dropped when connecting
+//
+// Deprecated "sslmode=" prefix of sslmode is removed and warned about to
+// given Logger
+//
+// Error is returned if sslmode is invalid, if only one of ssl cert and
+// ssl key is set, if ssl files are set along with sslmode=disable or if
+// any ssl file can't be read
+func applySSL(setting webutil.DatabaseSetting, log Logger) (webutil.DatabaseSetting, error) {
+	if strings.HasPrefix(setting.SSLMode, sslModePrefix) {
+		log.Warn(
+			"ssl mode with 'sslmode=' prefix is deprecated; use ssl mode value only",
+			"host", setting.Host,
+			"ssl_mode", setting.SSLMode,
+		)
+
+		setting.SSLMode = strings.TrimPrefix(setting.SSLMode, sslModePrefix)
+	}
+
+	if setting.SSLMode != "" {
+		valid := false
+
+		for _, mode := range ValidSSLModes {
+			if setting.SSLMode == mode {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			return setting, fmt.Errorf(
+				"invalid ssl mode '%s' for host '%s'.  Valid ssl modes are: %v",
+				setting.SSLMode,
+				setting.Host,
+				ValidSSLModes,
+			)
+		}
+	}
+
+	if setting.SSLMode == "disable" &&
+		(setting.SSLRootCert != "" || setting.SSLCert != "" || setting.SSLKey != "") {
+		return setting, fmt.Errorf(
+			"ssl root cert, ssl cert and ssl key can't be set along with ssl mode 'disable' for host '%s'",
+			setting.Host,
+		)
+	}
+
+	if (setting.SSLCert == "") != (setting.SSLKey == "") {
+		return setting, fmt.Errorf(
+			"ssl cert and ssl key must both be set for client certificates for host '%s'",
+			setting.Host,
+		)
+	}
+
+	sslFiles := []struct {
+		name string
+		path string
+	}{
+		{
+			name: "ssl root cert",
+			path: setting.SSLRootCert,
+		},
+		{
+			name: "ssl cert",
+			path: setting.SSLCert,
+		},
+		{
+			name: "ssl key",
+			path: setting.SSLKey,
+		},
+	}
+
+	for _, sslFile := range sslFiles {
+		if sslFile.path == "" {
+			continue
+		}
+
+		file, err := os.Open(sslFile.path)
+
+		if err != nil {
+			return setting, fmt.Errorf("can't read %s for host '%s': %v", sslFile.name, setting.Host, err)
+		}
+
+		file.Close()
+		setting.SSL = true
+	}
+
+	if setting.SSLMode != "" && setting.SSLMode != "disable" {
+		setting.SSL = true
+	}
+
+	return setting, nil
+}
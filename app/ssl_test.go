@@ -0,0 +1,105 @@
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TravisS25/webutil/webutil"
+)
+
+func TestApplySSL(t *testing.T) {
+	var err error
+
+	dir, err := ioutil.TempDir("", "cdbm-ssl")
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+
+	for _, f := range []string{certFile, keyFile} {
+		if err = ioutil.WriteFile(f, []byte("test"), 0600); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that invalid settings return errors
+	invalidSettings := []webutil.DatabaseSetting{
+		{
+			SSLMode: "sslmode=bogus",
+		},
+		{
+			SSLMode:     "disable",
+			SSLRootCert: certFile,
+		},
+		{
+			SSLCert: certFile,
+		},
+		{
+			SSLCert: certFile,
+			SSLKey:  filepath.Join(dir, "missing.key"),
+		},
+		{
+			SSLRootCert: filepath.Join(dir, "missing.crt"),
+		},
+	}
+
+	for _, setting := range invalidSettings {
+		if _, err = applySSL(setting, nopLogger{}); err == nil {
+			t.Errorf("should have error for setting %+v\n", setting)
+		}
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that ssl is turned on when ssl settings are used
+	setting, err := applySSL(webutil.DatabaseSetting{
+		SSLMode: "verify-full",
+		SSLCert: certFile,
+		SSLKey:  keyFile,
+	}, nopLogger{})
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if !setting.SSL {
+		t.Errorf("should have ssl turned on")
+	}
+
+	if setting, err = applySSL(webutil.DatabaseSetting{SSLMode: "disable"}, nopLogger{}); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if setting.SSL {
+		t.Errorf("should not have ssl turned on")
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that deprecated "sslmode=" prefix is accepted with a warning
+	var buf bytes.Buffer
+
+	log, _ := NewLogger(LevelWarn, LogFormatText, &buf)
+
+	if setting, err = applySSL(webutil.DatabaseSetting{SSLMode: "sslmode=require"}, log); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if setting.SSLMode != "require" || !setting.SSL {
+		t.Errorf("should have ssl mode require with ssl turned on; got %+v", setting)
+	}
+	if !strings.Contains(buf.String(), "deprecated") {
+		t.Errorf("should have deprecation warning; got %s", buf.String())
+	}
+}
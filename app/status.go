@@ -9,9 +9,17 @@ func (cdbm *CDBM) Status() error {
 	cdbm.migrateCfg.SchemaMigration, err = cdbm.getSchemaMigration()
 
 	if err != nil {
+		cdbm.logger().Error("can't query schema_migrations", "host", cdbm.currentDBSettings.Host, "error", err)
 		return err
 	}
 
+	cdbm.logger().Debug(
+		"queried schema_migrations",
+		"host", cdbm.currentDBSettings.Host,
+		"version", cdbm.migrateCfg.SchemaMigration.StartingVersion,
+		"dirty", cdbm.migrateCfg.SchemaMigration.Dirty,
+	)
+
 	// If there are no rows in table, then no migration has happended so print to stdout
 	//
 	// Else display current migration status
@@ -32,6 +40,64 @@ func (cdbm *CDBM) Status() error {
 				ds,
 			),
 		)
+
+		if cm, ok := cdbm.CustomMigrations[cdbm.migrateCfg.SchemaMigration.StartingVersion]; ok && cm.Description != "" {
+			fmt.Printf("description: %s\n", cm.Description)
+		}
+	}
+
+	// If migrations directory is set, we can compare migrations against the
+	// versions that have been applied and display the ones that were skipped
+	if cdbm.MigrateFlags.MigrationsDir != "" {
+		return cdbm.statusUnapplied()
+	}
+
+	return nil
+}
+
+// statusUnapplied prints out to stdout versions lower than current version
+// that have never been applied
+func (cdbm *CDBM) statusUnapplied() error {
+	cdbm.migrateCfg.CustomMigrations = cdbm.CustomMigrations
+
+	cfgs, err := cdbm.verifyFilesAndMigrations()
+
+	if err != nil {
+		return err
+	}
+
+	applied, found, err := cdbm.queryAppliedMigrations()
+
+	if err != nil {
+		return err
+	}
+
+	// If schema_migrations_applied doesn't exist yet, every version below current
+	// version will be considered applied on next migration
+	if !found {
+		return nil
+	}
+
+	cdbm.migrateCfg.AppliedVersions = applied
+
+	if cdbm.migrateCfg.SkippedVersions, _, err = cdbm.querySkippedMigrations(); err != nil {
+		return err
+	}
+
+	skipped := make([]int, 0, len(cdbm.migrateCfg.SkippedVersions))
+
+	for _, cfg := range cfgs {
+		if cdbm.migrateCfg.SkippedVersions[cfg.Version] {
+			skipped = append(skipped, cfg.Version)
+		}
+	}
+
+	if len(skipped) > 0 {
+		fmt.Printf("skipped versions: %v\n", skipped)
+	}
+
+	if unapplied := cdbm.getUnappliedMigrations(cfgs); len(unapplied) > 0 {
+		fmt.Printf("unapplied versions below current version: %v\n", unappliedVersions(unapplied))
 	}
 
 	return nil
@@ -0,0 +1,313 @@
+package app
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/pkg/errors"
+)
+
+// fileHeader is info found in the header comments of migration file
+type fileHeader struct {
+	// Tags are tags found in "-- cdbm:tags" comment
+	Tags []string
+
+	// Baseline is whether "-- cdbm:baseline" comment is found
+	Baseline bool
+}
+
+// readFileHeader reads the header comments of given migration file
+//
+// Header is every comment line at the top of file before first statement
+func readFileHeader(filePath string) (fileHeader, error) {
+	file, err := os.Open(filePath)
+
+	if err != nil {
+		return fileHeader{}, errors.WithStack(err)
+	}
+
+	defer file.Close()
+
+	header := fileHeader{Tags: make([]string, 0)}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		line = strings.TrimSpace(strings.TrimPrefix(line, "--"))
+
+		if line == cdbmutil.BaselineHeader {
+			header.Baseline = true
+			continue
+		}
+
+		if !strings.HasPrefix(line, cdbmutil.TagsHeader) {
+			continue
+		}
+
+		for _, tag := range strings.Split(strings.TrimPrefix(line, cdbmutil.TagsHeader), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				header.Tags = append(header.Tags, tag)
+			}
+		}
+	}
+
+	return header, errors.WithStack(scanner.Err())
+}
+
+// matchesTags determines whether any of given tags are found in tagSet
+func matchesTags(tags, tagSet []string) bool {
+	for _, tag := range tags {
+		for _, t := range tagSet {
+			if tag == t {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isSkippedByTags determines whether given config has a tag passed by --skip-tags
+// or its version is one of CDBM#skipVersions
+func (cdbm *CDBM) isSkippedByTags(cfg migrationApplyConfig) bool {
+	if cdbm.skipVersions[cfg.Version] {
+		return true
+	}
+
+	return len(cdbm.MigrateFlags.SkipTags) > 0 && matchesTags(cfg.Tags, cdbm.MigrateFlags.SkipTags)
+}
+
+// applySchemaSkippedQueries sets up our insert and delete schema_migrations_skipped
+// queries by applying sql bind var
+func (cdbm *CDBM) applySchemaSkippedQueries() error {
+	skippedInsert, _, err := webutil.InQueryRebind(
+		cdbm.DBProtocolCfg.SQLBindVar,
+		`
+		insert into schema_migrations_skipped(version, is_custom_migration)
+		values(?, ?)
+		on conflict (version) do nothing;
+		`,
+		0,
+		true,
+	)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	skippedDelete, _, err := webutil.InQueryRebind(
+		cdbm.DBProtocolCfg.SQLBindVar,
+		`
+		delete from schema_migrations_skipped where version = ?;
+		`,
+		0,
+	)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	skippedDeleteAbove, _, err := webutil.InQueryRebind(
+		cdbm.DBProtocolCfg.SQLBindVar,
+		`
+		delete from schema_migrations_skipped where version > ?;
+		`,
+		0,
+	)
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	cdbm.migrateCfg.SkippedInsertQuery = skippedInsert
+	cdbm.migrateCfg.SkippedDeleteQuery = skippedDelete
+	cdbm.migrateCfg.SkippedDeleteAboveQuery = skippedDeleteAbove
+	return nil
+}
+
+// querySkippedMigrations returns every version recorded in schema_migrations_skipped table
+//
+// Returned bool will be false if schema_migrations_skipped table doesn't exist
+func (cdbm *CDBM) querySkippedMigrations() (map[int]bool, bool, error) {
+	var err error
+
+	if err = cdbm.DBProtocolCfg.SkippedTableSearch(cdbm.DB); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+
+		return nil, false, errors.WithStack(err)
+	}
+
+	rows, err := cdbm.DB.Queryx(
+		`
+		select
+			schema_migrations_skipped.version
+		from
+			schema_migrations_skipped
+		`,
+	)
+
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+
+	defer rows.Close()
+
+	skipped := make(map[int]bool)
+
+	for rows.Next() {
+		var version int
+
+		if err = rows.Scan(&version); err != nil {
+			return nil, false, errors.WithStack(err)
+		}
+
+		skipped[version] = true
+	}
+
+	return skipped, true, errors.WithStack(rows.Err())
+}
+
+// getSkippedMigrations queries and returns versions recorded in schema_migrations_skipped table
+//
+// If schema_migrations_skipped table doesn't exist, it creates it
+func (cdbm *CDBM) getSkippedMigrations() (map[int]bool, error) {
+	skipped, found, err := cdbm.querySkippedMigrations()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		return skipped, nil
+	}
+
+	if _, err = cdbm.DB.Exec(
+		`
+		CREATE TABLE public.schema_migrations_skipped (
+			version INT8 NOT NULL primary key,
+			is_custom_migration boolean not null default false,
+			skipped_at timestamptz not null default now()
+		);
+		`,
+	); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return make(map[int]bool), nil
+}
+
+// skipMigration moves schema_migrations table to given config's version without
+// applying it and records version in schema_migrations_skipped table so it can
+// be applied later with --only-tags
+func (cdbm *CDBM) skipMigration(cfg migrationApplyConfig) error {
+	var err error
+	var query string
+
+	if cdbm.migrateCfg.SchemaMigration.SchemaCfg.NoRows {
+		query = cdbm.migrateCfg.InsertQuery
+	} else {
+		query = cdbm.migrateCfg.UpdateQuery
+	}
+
+	if _, err = cdbm.DB.Exec(query, cfg.Version, false, "", cfg.isCustomMigration()); err != nil {
+		cdbm.logger().Error("can't update schema_migrations", "version", cfg.Version, "kind", cfg.kind(), "error", err)
+
+		return errors.WithStack(err)
+	}
+
+	cdbm.migrateCfg.SchemaMigration.SchemaCfg.NoRows = false
+
+	if _, err = cdbm.DB.Exec(cdbm.migrateCfg.SkippedInsertQuery, cfg.Version, cfg.isCustomMigration()); err != nil {
+		cdbm.logger().Error("can't record skipped version", "version", cfg.Version, "kind", cfg.kind(), "error", err)
+
+		return errors.WithStack(err)
+	}
+
+	cdbm.migrateCfg.SkippedVersions[cfg.Version] = true
+
+	fmt.Printf("Skipped version %d with tags %v\n", cfg.Version, cfg.Tags)
+	return nil
+}
+
+// unskipMigration moves schema_migrations table down to given config's version
+// without applying down migration of skipped version above it
+func (cdbm *CDBM) unskipMigration(cfg, skippedCfg migrationApplyConfig) error {
+	if _, err := cdbm.DB.Exec(
+		cdbm.migrateCfg.UpdateQuery,
+		cfg.Version,
+		false,
+		"",
+		cfg.isCustomMigration(),
+	); err != nil {
+		cdbm.logger().Error("can't update schema_migrations", "version", cfg.Version, "kind", cfg.kind(), "error", err)
+
+		return errors.WithStack(err)
+	}
+
+	return cdbm.removeSkipped(skippedCfg.Version)
+}
+
+// unskipFirstMigration clears schema_migrations table, as migrate library does
+// when migrating down to version 0, without applying down migration of given
+// first config that was skipped
+func (cdbm *CDBM) unskipFirstMigration(skippedCfg migrationApplyConfig) error {
+	if err := cdbm.migrateCfg.FileMigration(
+		cdbm.migrateCfg.Migrate,
+		-1,
+		cdbmutil.MigrateTypeForce,
+	); err != nil {
+		cdbm.logger().Error("can't clear schema_migrations", "version", skippedCfg.Version, "kind", skippedCfg.kind(), "error", err)
+
+		return errors.WithStack(err)
+	}
+
+	cdbm.migrateCfg.SchemaMigration.SchemaCfg.NoRows = true
+	return cdbm.removeSkipped(skippedCfg.Version)
+}
+
+// removeSkipped removes given version from schema_migrations_skipped table
+func (cdbm *CDBM) removeSkipped(version int) error {
+	if _, err := cdbm.DB.Exec(cdbm.migrateCfg.SkippedDeleteQuery, version); err != nil {
+		cdbm.logger().Error("can't remove skipped version", "version", version, "error", err)
+
+		return errors.WithStack(err)
+	}
+
+	delete(cdbm.migrateCfg.SkippedVersions, version)
+	return nil
+}
+
+// applyOnlyTags applies every skipped version that has a tag passed by --only-tags
+func (cdbm *CDBM) applyOnlyTags(cfgs []migrationApplyConfig) error {
+	tagged := make([]migrationApplyConfig, 0)
+
+	for _, cfg := range cfgs {
+		if cdbm.migrateCfg.SkippedVersions[cfg.Version] && matchesTags(cfg.Tags, cdbm.MigrateFlags.OnlyTags) {
+			tagged = append(tagged, cfg)
+		}
+	}
+
+	if len(tagged) == 0 {
+		fmt.Printf("No skipped versions with tags %v\n", cdbm.MigrateFlags.OnlyTags)
+		return nil
+	}
+
+	// Applying version also removes it from schema_migrations_skipped table
+	return cdbm.applyOutOfOrderMigrations(tagged)
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestReadFileHeader(t *testing.T) {
+	var err error
+	var header fileHeader
+
+	migrationsDir := "/tmp/file-tags/"
+
+	if err = os.MkdirAll(migrationsDir, os.ModePerm); err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.RemoveAll(migrationsDir)
+
+	// --------------------------------------------------------------------------
+
+	if err = ioutil.WriteFile(
+		migrationsDir+"000001_update.up.sql",
+		[]byte(
+			`
+			-- Backfills names
+			-- cdbm:tags data, backfill
+
+			update foo set name = 'test';
+			-- cdbm:tags ignored
+			`,
+		),
+		os.ModePerm,
+	); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if header, err = readFileHeader(migrationsDir + "000001_update.up.sql"); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if !reflect.DeepEqual(header.Tags, []string{"data", "backfill"}) {
+		t.Errorf("should have tags [data backfill]; got %v\n", header.Tags)
+	}
+
+	// --------------------------------------------------------------------------
+
+	if err = ioutil.WriteFile(
+		migrationsDir+"000002_update.up.sql",
+		[]byte("create table bar(id serial);"),
+		os.ModePerm,
+	); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if header, err = readFileHeader(migrationsDir + "000002_update.up.sql"); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if len(header.Tags) != 0 {
+		t.Errorf("should not have tags; got %v\n", header.Tags)
+	}
+}
+
+func TestIsSkippedByTags(t *testing.T) {
+	c := &CDBM{
+		MigrateFlags: MigrateFlagsConfig{
+			SkipTags: []string{"backfill"},
+		},
+	}
+
+	if !c.isSkippedByTags(migrationApplyConfig{Tags: []string{"data", "backfill"}}) {
+		t.Errorf("should be skipped")
+	}
+	if c.isSkippedByTags(migrationApplyConfig{Tags: []string{"data"}}) {
+		t.Errorf("should not be skipped")
+	}
+	if c.isSkippedByTags(migrationApplyConfig{}) {
+		t.Errorf("should not be skipped")
+	}
+}
+
+func TestApplyOnlyTags(t *testing.T) {
+	var err error
+
+	dir, err := ioutil.TempDir("", "cdbm-only-tags")
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	cfgs := make([]migrationApplyConfig, 0)
+
+	for _, version := range []int{2, 3} {
+		upFile := filepath.Join(dir, fmt.Sprintf("%06d_backfill.up.sql", version))
+
+		if err = ioutil.WriteFile(upFile, []byte("update foo set name = 'test';"), os.ModePerm); err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		cfgs = append(cfgs, migrationApplyConfig{
+			Version: version,
+			UpFile:  upFile,
+			Tags:    []string{"backfill"},
+		})
+	}
+
+	db, mockDB, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	c := &CDBM{
+		DB: sqlx.NewDb(db, webutil.Postgres),
+		MigrateFlags: MigrateFlagsConfig{
+			OnlyTags: []string{"backfill"},
+		},
+		migrateCfg: migrateState{
+			TrackApplied:    true,
+			AppliedVersions: make(map[int]bool),
+			SkippedVersions: map[int]bool{
+				2: true,
+				3: true,
+			},
+		},
+	}
+
+	// Validating that failed skipped version is rolled back, stays skipped
+	// and stops versions after it from being applied
+	mockDB.ExpectBegin()
+	mockDB.ExpectExec("").WillReturnError(errors.New("syntax error"))
+	mockDB.ExpectRollback()
+
+	if err = c.applyOnlyTags(cfgs); err == nil {
+		t.Errorf("should have error")
+	}
+
+	if !c.migrateCfg.SkippedVersions[2] || !c.migrateCfg.SkippedVersions[3] {
+		t.Errorf("should have versions 2 and 3 skipped; got %v", c.migrateCfg.SkippedVersions)
+	}
+	if len(c.migrateCfg.AppliedVersions) != 0 {
+		t.Errorf("should not have applied versions; got %v", c.migrateCfg.AppliedVersions)
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that applied skipped version is recorded and removed from
+	// skipped versions in same transaction
+	mockDB.ExpectBegin()
+	mockDB.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+	mockDB.ExpectExec("").WithArgs(3, false).WillReturnResult(sqlmock.NewResult(1, 1))
+	mockDB.ExpectExec("").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mockDB.ExpectCommit()
+
+	if err = c.applyOnlyTags(cfgs[1:]); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if !c.migrateCfg.AppliedVersions[3] || c.migrateCfg.SkippedVersions[3] {
+		t.Errorf("should have version 3 applied and not skipped")
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf(err.Error())
+	}
+}
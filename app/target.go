@@ -0,0 +1,135 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+// TargetConfig is config struct used to override flag settings when a named
+// entry of CDBM#DatabaseConfig is selected with --target
+//
+// Each field is a map of the same keys used by its flag config, and only keys
+// that are set will override current settings, for example:
+//
+//	targets:
+//	  prod:
+//	    migrate_flags:
+//	      rollback_on_failure: true
+//	    log_flags:
+//	      log_file: /var/log/cdbm/prod.log
+type TargetConfig struct {
+	// MigrateFlags overrides CDBM#MigrateFlags
+	MigrateFlags map[string]interface{} `yaml:"migrate_flags" mapstructure:"migrate_flags"`
+
+	// LogFlags overrides CDBM#LogFlags
+	LogFlags map[string]interface{} `yaml:"log_flags" mapstructure:"log_flags"`
+
+	// DropFlags overrides CDBM#DropFlags
+	DropFlags map[string]interface{} `yaml:"drop_flags" mapstructure:"drop_flags"`
+
+	// DiffFlags overrides CDBM#DiffFlags
+	DiffFlags map[string]interface{} `yaml:"diff_flags" mapstructure:"diff_flags"`
+}
+
+// applyTarget verifies RootFlagsConfig#Target is an entry in CDBM#DatabaseConfig
+// and applies its overrides from CDBM#Targets if any
+func (cdbm *CDBM) applyTarget() error {
+	if cdbm.RootFlags.Target == "" {
+		return nil
+	}
+
+	if _, ok := cdbm.DatabaseConfig[cdbm.RootFlags.Target]; !ok {
+		return fmt.Errorf(
+			"invalid --target '%s'.  Valid --target values are: %v",
+			cdbm.RootFlags.Target,
+			cdbm.databaseConfigNames(),
+		)
+	}
+
+	targetCfg, ok := cdbm.Targets[cdbm.RootFlags.Target]
+
+	if !ok {
+		return nil
+	}
+
+	overrides := []struct {
+		settings map[string]interface{}
+		result   interface{}
+	}{
+		{
+			settings: targetCfg.MigrateFlags,
+			result:   &cdbm.MigrateFlags,
+		},
+		{
+			settings: targetCfg.LogFlags,
+			result:   &cdbm.LogFlags,
+		},
+		{
+			settings: targetCfg.DropFlags,
+			result:   &cdbm.DropFlags,
+		},
+		{
+			settings: targetCfg.DiffFlags,
+			result:   &cdbm.DiffFlags,
+		},
+	}
+
+	for _, override := range overrides {
+		if override.settings == nil {
+			continue
+		}
+
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			WeaklyTypedInput: true,
+			ErrorUnused:      true,
+			Result:           override.result,
+		})
+
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if err = decoder.Decode(override.settings); err != nil {
+			return errors.Wrapf(err, "invalid settings for target '%s'", cdbm.RootFlags.Target)
+		}
+	}
+
+	return nil
+}
+
+// databaseConfigNames returns sorted names of every entry in CDBM#DatabaseConfig
+func (cdbm *CDBM) databaseConfigNames() []string {
+	names := make([]string, 0, len(cdbm.DatabaseConfig))
+
+	for name := range cdbm.DatabaseConfig {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// fallbackSettings returns database settings from CDBM#DatabaseConfig in the
+// order they should be tried
+//
+// If RootFlagsConfig#Target is set, only settings of that entry are returned,
+// else every entry is returned sorted by name with each entry's settings kept
+// in the order they were listed
+func (cdbm *CDBM) fallbackSettings() []DatabaseSetting {
+	names := cdbm.databaseConfigNames()
+
+	if cdbm.RootFlags.Target != "" {
+		names = []string{cdbm.RootFlags.Target}
+	}
+
+	settings := make([]DatabaseSetting, 0)
+
+	for _, name := range names {
+		settings = append(settings, cdbm.DatabaseConfig[name]...)
+	}
+
+	return settings
+}
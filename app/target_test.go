@@ -0,0 +1,119 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/TravisS25/webutil/webutil"
+)
+
+func TestApplyTarget(t *testing.T) {
+	var err error
+
+	c := &CDBM{
+		DatabaseConfig: map[string][]DatabaseSetting{
+			"dev": {
+				{
+					DatabaseSetting: webutil.DatabaseSetting{
+						BaseAuthSetting: webutil.BaseAuthSetting{
+							Host: "localhost",
+						},
+					},
+				},
+			},
+			"prod": {
+				{
+					DatabaseSetting: webutil.DatabaseSetting{
+						BaseAuthSetting: webutil.BaseAuthSetting{
+							Host: "prod-1",
+						},
+					},
+				},
+			},
+		},
+		Targets: map[string]TargetConfig{
+			"prod": {
+				MigrateFlags: map[string]interface{}{
+					"rollback_on_failure": true,
+					"skip_tags":           []interface{}{"seed"},
+				},
+				LogFlags: map[string]interface{}{
+					"log_file": "/var/log/cdbm/prod.log",
+				},
+			},
+		},
+		MigrateFlags: MigrateFlagsConfig{
+			MigrationsDir: "/migrations",
+		},
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that target without overrides doesn't change settings
+	c.RootFlags.Target = "dev"
+
+	if err = c.applyTarget(); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if c.MigrateFlags.RollbackOnFailure {
+		t.Errorf("should not have rollback on failure set")
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that only set keys are overridden
+	c.RootFlags.Target = "prod"
+
+	if err = c.applyTarget(); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if !c.MigrateFlags.RollbackOnFailure {
+		t.Errorf("should have rollback on failure set")
+	}
+
+	if len(c.MigrateFlags.SkipTags) != 1 || c.MigrateFlags.SkipTags[0] != "seed" {
+		t.Errorf("should have skip tags [seed]; got %v\n", c.MigrateFlags.SkipTags)
+	}
+
+	if c.MigrateFlags.MigrationsDir != "/migrations" {
+		t.Errorf("should have kept migrations dir; got %s\n", c.MigrateFlags.MigrationsDir)
+	}
+
+	if c.LogFlags.LogFile != "/var/log/cdbm/prod.log" {
+		t.Errorf("should have log file overridden; got %s\n", c.LogFlags.LogFile)
+	}
+
+	settings := c.fallbackSettings()
+
+	if len(settings) != 1 || settings[0].Host != "prod-1" {
+		t.Errorf("should only have prod settings; got %v\n", settings)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that unknown target and unknown override keys return errors
+	c.RootFlags.Target = "staging"
+
+	if err = c.applyTarget(); err == nil {
+		t.Errorf("should have error for unknown target")
+	}
+
+	c.RootFlags.Target = "prod"
+	c.Targets["prod"].MigrateFlags["not_a_flag"] = true
+
+	if err = c.applyTarget(); err == nil {
+		t.Errorf("should have error for unknown override key")
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that without target every entry is returned sorted by name
+	c.RootFlags.Target = ""
+
+	settings = c.fallbackSettings()
+
+	if len(settings) != 2 || settings[0].Host != "localhost" || settings[1].Host != "prod-1" {
+		t.Errorf("should have dev settings before prod settings; got %v\n", settings)
+	}
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is name of tracer every span of cdbm is started with
+const tracerName = "github.com/TravisS25/cdbm"
+
+// Below are attribute keys of spans started by CDBM#Migrate
+const (
+	attrDatabase      = attribute.Key("db.name")
+	attrVersion       = attribute.Key("cdbm.migration.version")
+	attrKind          = attribute.Key("cdbm.migration.kind")
+	attrDirection     = attribute.Key("cdbm.migration.direction")
+	attrTargetVersion = attribute.Key("cdbm.migration.target_version")
+	attrRowsAffected  = attribute.Key("cdbm.migration.rows_affected")
+)
+
+// tracer returns tracer of CDBM#TracerProvider or tracer that records
+// nothing if not set
+func (cdbm *CDBM) tracer() trace.Tracer {
+	if cdbm.TracerProvider == nil {
+		return trace.NewNoopTracerProvider().Tracer(tracerName)
+	}
+
+	return cdbm.TracerProvider.Tracer(tracerName)
+}
+
+// migrateContext returns context of current span of CDBM#Migrate or
+// context.Background if no span is started
+func (cdbm *CDBM) migrateContext() context.Context {
+	if cdbm.migrateCfg.ctx == nil {
+		return context.Background()
+	}
+
+	return cdbm.migrateCfg.ctx
+}
+
+// startMigrateSpan starts span of whole migration run which every version
+// span is nested under and returns function to end it with error of run
+func (cdbm *CDBM) startMigrateSpan() func(error) {
+	ctx, span := cdbm.tracer().Start(
+		context.Background(),
+		"cdbm.migrate",
+		trace.WithAttributes(attrDatabase.String(cdbm.currentDBSettings.DBName)),
+	)
+
+	cdbm.migrateCfg.ctx = ctx
+
+	return func(err error) {
+		span.SetAttributes(attrTargetVersion.Int(cdbm.migrateCfg.TargetVersion))
+
+		if cdbm.migrateCfg.MigrateType != "" {
+			span.SetAttributes(attrDirection.String(strings.ToLower(string(cdbm.migrateCfg.MigrateType))))
+		}
+
+		endSpan(span, cdbm.redactErr(err))
+		cdbm.migrateCfg.ctx = nil
+	}
+}
+
+// startVersionSpan starts span of applying given version of given kind
+// nested under current span and returns function to end it with error of version
+//
+// Custom migrations of version get context of span through cdbmutil.MigrationContext
+func (cdbm *CDBM) startVersionSpan(version int, kind string) func(error) {
+	parent := cdbm.migrateContext()
+	ctx, span := cdbm.tracer().Start(
+		parent,
+		"cdbm.migration",
+		trace.WithAttributes(
+			attrVersion.Int(version),
+			attrKind.String(kind),
+			attrDirection.String(strings.ToLower(string(cdbm.migrateCfg.MigrateType))),
+		),
+	)
+
+	cdbm.migrateCfg.ctx = ctx
+
+	return func(err error) {
+		endSpan(span, cdbm.redactErr(err))
+		cdbm.migrateCfg.ctx = parent
+	}
+}
+
+// endSpan records given error on span, if not nil, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
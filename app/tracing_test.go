@@ -0,0 +1,133 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/TravisS25/webutil/webutil"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	var err error
+
+	db, mockDB, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlAnyMatcher))
+
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	mApp := &CDBM{
+		DB:             sqlx.NewDb(db, webutil.Postgres),
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		migrateCfg: migrateState{
+			MigrateType:   cdbmutil.MigrateTypeUp,
+			TargetVersion: 3,
+		},
+	}
+
+	// Validating that custom migration gets context of version span and
+	// that version spans are nested under migrate span with their attributes
+	var migrationSpan trace.SpanContext
+
+	upFunc := func(db webutil.DBInterface) error {
+		migrationSpan = trace.SpanContextFromContext(cdbmutil.MigrationContext(db))
+		_, innerErr := db.Exec("update foo set name = 'test';")
+		return innerErr
+	}
+
+	migrationErr := errors.New("migration error")
+	endMigrate := mApp.startMigrateSpan()
+
+	mockDB.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 3))
+	endVersion := mApp.startVersionSpan(2, migrationKindCustom)
+
+	if err = mApp.runCustomMigration(cdbmutil.CustomMigration{}, upFunc, nil); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	endVersion(nil)
+	endVersion = mApp.startVersionSpan(3, migrationKindFile)
+	endVersion(migrationErr)
+	endMigrate(migrationErr)
+
+	if mApp.migrateCfg.ctx != nil {
+		t.Errorf("should have reset context once migrate span ended")
+	}
+
+	spans := exporter.GetSpans()
+
+	if len(spans) != 3 {
+		t.Fatalf("should have 3 spans; got %d", len(spans))
+	}
+
+	migrateSpan := spans[2]
+
+	if migrateSpan.Name != "cdbm.migrate" {
+		t.Fatalf("last span should be cdbm.migrate; got %s", migrateSpan.Name)
+	}
+	if migrateSpan.Status.Code != codes.Error {
+		t.Errorf("migrate span should have error status")
+	}
+
+	for i, version := range []int{2, 3} {
+		span := spans[i]
+		attrs := attribute.NewSet(span.Attributes...)
+
+		if span.Parent.SpanID() != migrateSpan.SpanContext.SpanID() {
+			t.Errorf("version %d span should be nested under migrate span", version)
+		}
+		if v, _ := attrs.Value(attrVersion); v.AsInt64() != int64(version) {
+			t.Errorf("span should have version %d; got %d", version, v.AsInt64())
+		}
+		if v, _ := attrs.Value(attrDirection); v.AsString() != "up" {
+			t.Errorf("span should have direction up; got %s", v.AsString())
+		}
+	}
+
+	attrs := attribute.NewSet(spans[0].Attributes...)
+
+	if migrationSpan.SpanID() != spans[0].SpanContext.SpanID() {
+		t.Errorf("custom migration should have context of version span")
+	}
+	if v, _ := attrs.Value(attrKind); v.AsString() != migrationKindCustom {
+		t.Errorf("span should have kind %s; got %s", migrationKindCustom, v.AsString())
+	}
+	if v, _ := attrs.Value(attrRowsAffected); v.AsInt64() != 3 {
+		t.Errorf("span should have 3 rows affected; got %d", v.AsInt64())
+	}
+	if spans[0].Status.Code == codes.Error {
+		t.Errorf("version 2 span should not have error status")
+	}
+	if spans[1].Status.Code != codes.Error || len(spans[1].Events) == 0 {
+		t.Errorf("version 3 span should have recorded error")
+	}
+
+	if err = mockDB.ExpectationsWereMet(); err != nil {
+		t.Errorf("should not have error; got %+v", err)
+	}
+
+	// --------------------------------------------------------------------------
+
+	// Validating that spans are not recorded without TracerProvider
+	mApp.TracerProvider = nil
+	endMigrate = mApp.startMigrateSpan()
+
+	if trace.SpanContextFromContext(mApp.migrateContext()).IsValid() {
+		t.Errorf("should not have recorded span")
+	}
+
+	endMigrate(nil)
+
+	if ctx := cdbmutil.MigrationContext(mApp.DB); ctx == nil {
+		t.Errorf("should have background context for db not passed by cdbm")
+	}
+}
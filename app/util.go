@@ -1,6 +1,7 @@
 package app
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/TravisS25/webutil/webutil"
@@ -8,24 +9,46 @@ import (
 	"github.com/spf13/viper"
 )
 
+// GetCDBMConfig reads config files from paths set in env enviroment variable
+// into *CDBM
+//
+// If env is empty string, then CDBM_CONFIG is used as default and if it's not
+// set, config is only built from enviroment variables as config file is optional
+//
+// Enviroment variable can hold multiple paths separated by os path list separator
+// ie. "base.yml:prod.yml:local.yml" which are merged in order, and "${VAR}" or
+// "${VAR:-default}" within files are replaced by enviroment variables
+//
+// Every flag config field can also be set by enviroment variable ie. CDBM_ROOT_FLAGS_HOST
+//
+// MigrateFlagsConfig#TargetVersion defaults to -1 so database is migrated to
+// latest version unless target version is set
 func GetCDBMConfig(env string) (*CDBM, error) {
 	var cdbm CDBM
 	var err error
 	var envUsed string
 
 	if env != "" {
-		envUsed = os.Getenv(env)
+		if envUsed = os.Getenv(env); envUsed == "" {
+			return nil, fmt.Errorf("enviroment variable '%s' set by --env is not set", env)
+		}
 	} else {
 		envUsed = os.Getenv(CDBM_CONFIG)
 	}
 
-	viper.SetConfigFile(envUsed)
+	v := viper.New()
 
-	if err = viper.ReadInConfig(); err != nil {
-		return nil, errors.WithStack(err)
+	if err = readConfigFiles(v, configFilePaths(envUsed)); err != nil {
+		return nil, err
 	}
 
-	if viper.Unmarshal(&cdbm); err != nil {
+	if err = bindFlagsEnvs(v); err != nil {
+		return nil, err
+	}
+
+	v.SetDefault("migrate_flags.target_version", -1)
+
+	if err = v.Unmarshal(&cdbm); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
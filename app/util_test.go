@@ -2,6 +2,7 @@ package app
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"testing"
 
@@ -9,6 +10,32 @@ import (
 	"github.com/TravisS25/webutil/webutil"
 )
 
+func TestGetCDBMConfigDefaults(t *testing.T) {
+	if env, ok := os.LookupEnv(CDBM_CONFIG); ok {
+		os.Unsetenv(CDBM_CONFIG)
+		defer os.Setenv(CDBM_CONFIG, env)
+	}
+
+	// Validating that migrating without config file targets latest version
+	cdbm, err := GetCDBMConfig("")
+
+	if err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if cdbm.MigrateFlags.TargetVersion != -1 {
+		t.Errorf("should default target version to -1; got %d\n", cdbm.MigrateFlags.TargetVersion)
+	}
+
+	if err = cdbm.applyTargetVersion([]migrationApplyConfig{{Version: 1}, {Version: 2}}); err != nil {
+		t.Fatalf("should not have error; got %+v", err)
+	}
+
+	if cdbm.migrateCfg.TargetVersion != 2 {
+		t.Errorf("should have target latest version 2; got %d\n", cdbm.migrateCfg.TargetVersion)
+	}
+}
+
 func TestAppendValuesQuery(t *testing.T) {
 	var err error
 
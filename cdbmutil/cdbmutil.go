@@ -1,8 +1,10 @@
 package cdbmutil
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/TravisS25/webutil/webutil"
 	migrate "github.com/golang-migrate/migrate/v4"
@@ -52,6 +54,16 @@ const (
 	GoogleCloudStorageProtocol MigrationsProtocol = "gcs://"
 )
 
+const (
+	// TagsHeader is comment prefix used in the header of an up migration file to
+	// give the file tags ie. "-- cdbm:tags data,backfill"
+	TagsHeader = "cdbm:tags"
+
+	// BaselineHeader is comment used in the header of an up migration file to
+	// mark it as baseline of squashed migrations ie. "-- cdbm:baseline"
+	BaselineHeader = "cdbm:baseline"
+)
+
 const (
 	// CDBM_UTIL_CONFIG is default enviroment variable used to point to config file for cdmbutil
 	CDBM_UTIL_CONFIG = "CDBM_UTIL_CONFIG"
@@ -78,8 +90,24 @@ type MigrationsProtocol string
 type FileMigrationFunc func(mig *migrate.Migrate, version int, mt MigrationsType) error
 
 // CustomMigrationFunc should implement migrating database up or down through custom code
+//
+// Use MigrationContext to get context of migration from given db
 type CustomMigrationFunc func(db webutil.DBInterface) error
 
+// MigrationContext returns context of custom migration from db passed to
+// CustomMigrationFunc which is cancelled once CustomMigration#Timeout is reached
+// and carries trace span of migration's version so queries and spans started
+// with it are nested under it
+//
+// If db was not passed by cdbm, context.Background is returned
+func MigrationContext(db webutil.DBInterface) context.Context {
+	if c, ok := db.(interface{ Context() context.Context }); ok {
+		return c.Context()
+	}
+
+	return context.Background()
+}
+
 // GetMigrationFunc should implement getting migrate.Migrate based on migrations
 // directory and database instance
 type GetMigrationFunc func(migDir string, db *sql.DB, protocolCfg DBProtocolConfig) (*migrate.Migrate, error)
@@ -96,12 +124,30 @@ type DBProtocolConfig struct {
 	// DBProtocol is what protocol to use when connecting to database
 	DBProtocol DBProtocol
 
+	// DSNSchemes are url schemes that are valid for a dsn of database
+	DSNSchemes []string
+
+	// DefaultPort is port used if a dsn doesn't set one
+	DefaultPort int
+
 	// MigrationTableSearch determines if schema_migrations table exists
 	// in database or not
 	//
 	// Should return nil if schema_migrations is found
 	MigrationTableSearch func(db webutil.DBInterface) error
 
+	// AppliedTableSearch determines if schema_migrations_applied table exists
+	// in database or not
+	//
+	// Should return nil if schema_migrations_applied is found
+	AppliedTableSearch func(db webutil.DBInterface) error
+
+	// SkippedTableSearch determines if schema_migrations_skipped table exists
+	// in database or not
+	//
+	// Should return nil if schema_migrations_skipped is found
+	SkippedTableSearch func(db webutil.DBInterface) error
+
 	// DriverConfig is config struct used for migrate library
 	// for different settings based on database
 	DriverConfig interface{}
@@ -120,6 +166,33 @@ type CustomMigration struct {
 
 	// Down should migrate database to previous state
 	Down CustomMigrationFunc
+
+	// Description is short summary of what migration does and is displayed
+	// by status and history commands
+	Description string
+
+	// Author is who wrote the migration
+	Author string
+
+	// Transaction will run Up and Down within a single transaction when set
+	// and roll everything back if they return error
+	Transaction bool
+
+	// Timeout is max amount of time Up and Down have to finish
+	//
+	// Any query still running through the given database once timeout is
+	// reached is cancelled
+	Timeout time.Duration
+
+	// Tags are labels used to group migrations which can be used to skip
+	// or only run certain migrations
+	Tags []string
+
+	// Idempotent declares that Up can safely be applied more than once
+	//
+	// When set, Down will not be ran to reset a dirty migration before Up is
+	// ran again
+	Idempotent bool
 }
 
 type FileServerSetup struct {
@@ -141,6 +214,11 @@ type DBAction struct {
 	CreateDB string       `yaml:"create_db" mapstructure:"create_db"`
 	DropDB   string       `yaml:"drop_db" mapstructure:"drop_db"`
 	Import   ImportConfig `yaml:"import" mapstructure:"import"`
+
+	// DumpSchema is command that writes schema of database, without data, as
+	// sql to stdout where "%s" is replaced by database name ie.
+	// "pg_dump --schema-only --no-owner %s"
+	DumpSchema string `yaml:"dump_schema" mapstructure:"dump_schema"`
 }
 
 type BaseDatabaseSettings struct {
@@ -20,22 +20,44 @@ var (
 		PostgresProtocol: {
 			DBProtocol:           PostgresProtocol,
 			DatabaseType:         webutil.Postgres,
+			DSNSchemes:           []string{"postgres", "postgresql"},
+			DefaultPort:          5432,
 			SQLBindVar:           sqlx.DOLLAR,
 			DriverConfig:         &postgres.Config{},
 			MigrationTableSearch: postgresMigrationTableSearch,
+			AppliedTableSearch:   postgresAppliedTableSearch,
+			SkippedTableSearch:   postgresSkippedTableSearch,
 		},
 		CockroachdbProtocol: {
 			DBProtocol:           CockroachdbProtocol,
 			DatabaseType:         webutil.Postgres,
+			DSNSchemes:           []string{"cockroachdb", "cockroach", "postgres", "postgresql"},
+			DefaultPort:          26257,
 			SQLBindVar:           sqlx.DOLLAR,
 			DriverConfig:         &cockroachdb.Config{},
 			MigrationTableSearch: postgresMigrationTableSearch,
+			AppliedTableSearch:   postgresAppliedTableSearch,
+			SkippedTableSearch:   postgresSkippedTableSearch,
 		},
 	}
 
 	// postgresMigrationTableSearch is default search function for postgres to
 	// determine if schema_migrations table exists in database table
-	postgresMigrationTableSearch = func(db webutil.DBInterface) error {
+	postgresMigrationTableSearch = postgresTableSearch("schema_migrations")
+
+	// postgresAppliedTableSearch is default search function for postgres to
+	// determine if schema_migrations_applied table exists in database table
+	postgresAppliedTableSearch = postgresTableSearch("schema_migrations_applied")
+
+	// postgresSkippedTableSearch is default search function for postgres to
+	// determine if schema_migrations_skipped table exists in database table
+	postgresSkippedTableSearch = postgresTableSearch("schema_migrations_skipped")
+)
+
+// postgresTableSearch returns search function for postgres that determines
+// if given table exists in public schema
+func postgresTableSearch(tableName string) func(db webutil.DBInterface) error {
+	return func(db webutil.DBInterface) error {
 		var filler string
 		var err error
 
@@ -48,15 +70,16 @@ var (
 			where  
 				table_schema = 'public'
 			and    
-				table_name = 'schema_migrations' 
+				table_name = $1
 			`,
+			tableName,
 		).Scan(&filler); err != nil {
 			return err
 		}
 
 		return nil
 	}
-)
+}
 
 // DefaultExecCmd is default function for executing a command line tool
 func DefaultExecCmd(c *exec.Cmd) error {
@@ -0,0 +1,57 @@
+package cdbmutil
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[int]CustomMigration)
+)
+
+// Register makes custom migration available to cdbm commands by given version
+//
+// This is meant to be called from an init function of a package that is imported
+// by your own cdbm main package so the stock commands can run your custom migrations
+//
+// Register will panic if version is less than 1, if migration doesn't have both an up
+// and down function or if version is already registered
+func Register(version int, migration CustomMigration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if version < 1 {
+		panic(fmt.Sprintf("cdbmutil: Register version %d less than min version allowed (1)", version))
+	}
+	if migration.Up == nil || migration.Down == nil {
+		panic(fmt.Sprintf("cdbmutil: Register version %d must have both an up and down function", version))
+	}
+	if _, ok := registry[version]; ok {
+		panic(fmt.Sprintf("cdbmutil: Register called twice for version %d", version))
+	}
+
+	registry[version] = migration
+}
+
+// RegisteredMigrations returns copy of every custom migration added through Register
+func RegisteredMigrations() map[int]CustomMigration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	migrations := make(map[int]CustomMigration, len(registry))
+
+	for k, v := range registry {
+		migrations[k] = v
+	}
+
+	return migrations
+}
+
+// unregisterAll clears registry and is used for testing
+func unregisterAll() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry = make(map[int]CustomMigration)
+}
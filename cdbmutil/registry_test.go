@@ -0,0 +1,52 @@
+package cdbmutil
+
+import (
+	"testing"
+
+	"github.com/TravisS25/webutil/webutil"
+)
+
+func TestRegister(t *testing.T) {
+	defer unregisterAll()
+
+	cm := CustomMigration{
+		Up: func(db webutil.DBInterface) error {
+			return nil
+		},
+		Down: func(db webutil.DBInterface) error {
+			return nil
+		},
+	}
+
+	shouldPanic := func(version int, migration CustomMigration, msg string) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("should have panicked")
+			} else if r.(string) != msg {
+				t.Errorf("should have panic %s; got %s\n", msg, r)
+			}
+		}()
+
+		Register(version, migration)
+	}
+
+	shouldPanic(0, cm, "cdbmutil: Register version 0 less than min version allowed (1)")
+	shouldPanic(2, CustomMigration{Up: cm.Up}, "cdbmutil: Register version 2 must have both an up and down function")
+
+	Register(2, cm)
+
+	shouldPanic(2, cm, "cdbmutil: Register called twice for version 2")
+
+	migrations := RegisteredMigrations()
+
+	if len(migrations) != 1 {
+		t.Fatalf("should have 1 registered migration; got %d\n", len(migrations))
+	}
+
+	// Modifying returned map should not modify registry
+	delete(migrations, 2)
+
+	if len(RegisteredMigrations()) != 1 {
+		t.Errorf("registry should not be modified by returned map")
+	}
+}
@@ -23,8 +23,6 @@ import (
 //
 // If envVar is empty string, then CDBM_UTIL_CONFIG is used as default
 func GetCDBMUtilSettings(envVar string) (CDBMUtilSettings, error) {
-	var settings CDBMUtilSettings
-	var err error
 	var envUsed string
 
 	if envVar != "" {
@@ -33,13 +31,22 @@ func GetCDBMUtilSettings(envVar string) (CDBMUtilSettings, error) {
 		envUsed = os.Getenv(CDBM_UTIL_CONFIG)
 	}
 
-	viper.SetConfigFile(envUsed)
+	return ReadCDBMUtilSettings(envUsed)
+}
+
+// ReadCDBMUtilSettings retrieves CDBMUtilSettings from config file at given path
+func ReadCDBMUtilSettings(path string) (CDBMUtilSettings, error) {
+	var settings CDBMUtilSettings
+	var err error
+
+	v := viper.New()
+	v.SetConfigFile(path)
 
-	if err = viper.ReadInConfig(); err != nil {
+	if err = v.ReadInConfig(); err != nil {
 		return CDBMUtilSettings{}, errors.WithStack(err)
 	}
 
-	if viper.Unmarshal(&settings); err != nil {
+	if err = v.Unmarshal(&settings); err != nil {
 		return CDBMUtilSettings{}, errors.WithStack(err)
 	}
 
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"github.com/TravisS25/cdbm/app"
+	"github.com/spf13/cobra"
+)
+
+type configNameConfig struct {
+	Output flagName
+	Force  flagName
+}
+
+var configNameCfg = configNameConfig{
+	Output: flagName{
+		LongHand:  "output",
+		ShortHand: "o",
+	},
+	Force: flagName{
+		LongHand: "force",
+	},
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manages cdbm config",
+	Long: `Manages cdbm config without connecting to database on startup
+
+Available subcommands: init | show | validate
+`,
+	// Overrides root command so config commands don't connect to database
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+}
+
+// configInitCmd represents the config init command
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Writes starter config file",
+	Long: `Writes commented starter config file with every setting of root_flags,
+migrate_flags, log_flags, drop_flags, diff_flags, database_config and targets
+
+Writes to stdout if --output is not set
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString(configNameCfg.Output.LongHand)
+		force, _ := cmd.Flags().GetBool(configNameCfg.Force.LongHand)
+
+		return app.ConfigInit(output, force)
+	},
+}
+
+// configShowCmd represents the config show command
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Displays effective config",
+	Long: `Displays effective config merged from flags, enviroment variables and
+config files along with where each setting came from with passwords masked
+
+Displays: "%s: %s (%s)" as key, value and source (flag | target | env | file | default)
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return app.ConfigShow(rootFlagsCfg)
+	},
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates config and probes every connection",
+	Long: `Validates effective config and probes every connection set by root flags
+and every entry of database_config, displaying result of each
+
+Returns error if any problem was found
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return app.ConfigValidate(rootFlagsCfg)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	configInitCmd.Flags().StringP(
+		configNameCfg.Output.LongHand,
+		configNameCfg.Output.ShortHand,
+		"",
+		"File path to write config to",
+	)
+	configInitCmd.Flags().Bool(
+		configNameCfg.Force.LongHand,
+		false,
+		"Overwrite config file if it already exists",
+	)
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Detects drift between database schema and migrations",
+	Long: `Creates scratch database, migrates it to current version of database and
+compares schemas of both, displaying every difference such as changes applied
+by hand, missing indexes or column type mismatches
+
+Scratch database is created and dropped with commands of cdbmutil config set by
+--util-config or CDBM_UTIL_CONFIG enviroment variable
+
+Exits with non-zero status if any difference is found
+`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		applyScratchFlags(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer globalApp.DB.Close()
+		return globalApp.Diff(
+			execOpts.GetMigrationFunc,
+			execOpts.FileMigrationFunc,
+			execOpts.CustomMigrations,
+		)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	addScratchFlags(
+		diffCmd,
+		"cdbmutil config file used to create and drop scratch database",
+	)
+}
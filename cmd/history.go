@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Displays every applied migration version",
+	Long: `Displays every version recorded as applied along with when it was applied
+and the description of custom migrations
+
+Displays: "version:%d / kind:%s / applied at:%s / description:%s"
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return globalApp.History()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
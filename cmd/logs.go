@@ -16,67 +16,115 @@ limitations under the License.
 package cmd
 
 import (
-	"fmt"
-
+	"github.com/TravisS25/cdbm/app"
 	"github.com/spf13/cobra"
 )
 
 type logsNameConfig struct {
 	LogFile flagName
+	Since   flagName
+	Until   flagName
+	Version flagName
+	Level   flagName
+	Tail    flagName
+	Follow  flagName
 }
 
-var logsNameCfg = migrateNameConfig{
+var logsNameCfg = logsNameConfig{
 	LogFile: flagName{
-		LongHand:  "migrations-dir",
-		ShortHand: "m",
+		LongHand:  "log-file",
+		ShortHand: "l",
+	},
+	Since: flagName{
+		LongHand: "since",
+	},
+	Until: flagName{
+		LongHand: "until",
+	},
+	Version: flagName{
+		LongHand: "version",
+	},
+	Level: flagName{
+		LongHand: "level",
+	},
+	Tail: flagName{
+		LongHand:  "tail",
+		ShortHand: "n",
+	},
+	Follow: flagName{
+		LongHand:  "follow",
+		ShortHand: "f",
 	},
 }
 
+var logsFlagsCfg app.LogFlagsConfig
+
 // logsCmd represents the logs command
 var logsCmd = &cobra.Command{
 	Use:   "logs",
-	Short: "Display error logs",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	PreRunE: func(cmd *cobra.Command, args []string) error {
-		migrationDir, _ := cmd.Flags().GetString(migrateNameCfg.LogFile.LongHand)
+	Short: "Display logs",
+	Long: `Displays entries of log file along with its rotated log files, oldest first
 
-		if migrationDir != "" {
-			globalApp.LogFlags.LogFile = migrationDir
-		}
+Log file defaults to log_flags.log_file of config file
 
-		if globalApp.LogFlags.LogFile == "" {
-			return fmt.Errorf("--migrations-dir is required")
-		}
+Entries can be filtered by time, migration version and level where --since
+and --until take RFC3339 timestamp or duration before now ie. 2021-01-02T15:04:05Z or 24h
 
-		return nil
-	},
+Lines that are not structured log entries are only displayed if no filter is set
+`,
+	// Overrides root command so logs can be read without connecting to database
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return globalApp.Logs()
+		return app.Logs(rootFlagsCfg, logsFlagsCfg)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(logsCmd)
 
-	logsCmd.Flags().StringP(
+	logsCmd.Flags().StringVarP(
+		&logsFlagsCfg.LogFile,
 		logsNameCfg.LogFile.LongHand,
 		logsNameCfg.LogFile.ShortHand,
 		"",
-		"Directory where migration files are located",
+		"Log file to display",
+	)
+	logsCmd.Flags().StringVar(
+		&logsFlagsCfg.Since,
+		logsNameCfg.Since.LongHand,
+		"",
+		"Only display entries at or after given RFC3339 timestamp or duration before now",
+	)
+	logsCmd.Flags().StringVar(
+		&logsFlagsCfg.Until,
+		logsNameCfg.Until.LongHand,
+		"",
+		"Only display entries at or before given RFC3339 timestamp or duration before now",
+	)
+	logsCmd.Flags().IntVar(
+		&logsFlagsCfg.Version,
+		logsNameCfg.Version.LongHand,
+		0,
+		"Only display entries of given migration version",
+	)
+	logsCmd.Flags().StringVar(
+		&logsFlagsCfg.Level,
+		logsNameCfg.Level.LongHand,
+		"",
+		"Only display entries at or above given level.  Available values: debug | info | warn | error",
+	)
+	logsCmd.Flags().IntVarP(
+		&logsFlagsCfg.Tail,
+		logsNameCfg.Tail.LongHand,
+		logsNameCfg.Tail.ShortHand,
+		0,
+		"Only display last given number of entries",
+	)
+	logsCmd.Flags().BoolVarP(
+		&logsFlagsCfg.Follow,
+		logsNameCfg.Follow.LongHand,
+		logsNameCfg.Follow.ShortHand,
+		false,
+		"Keep displaying new entries as they are written until interrupted",
 	)
-
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// logsCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// logsCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
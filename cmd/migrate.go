@@ -26,9 +26,17 @@ type migrateNameConfig struct {
 	ResetDirtyFlag     flagName
 	TargetVersion      flagName
 	RollbackOnFailure  flagName
-	LogFile            flagName
+	MigrationsDir      flagName
 	MigrationsProtocol flagName
 	MigrateDownOnDirty flagName
+	Up                 flagName
+	Down               flagName
+	AllowOutOfOrder    flagName
+	SkipTags           flagName
+	OnlyTags           flagName
+	Verbose            flagName
+	MetricsFile        flagName
+	SnapshotFile       flagName
 }
 
 var migrateNameCfg = migrateNameConfig{
@@ -40,7 +48,7 @@ var migrateNameCfg = migrateNameConfig{
 		LongHand:  "rollback-on-failure",
 		ShortHand: "f",
 	},
-	LogFile: flagName{
+	MigrationsDir: flagName{
 		LongHand:  "migrations-dir",
 		ShortHand: "m",
 	},
@@ -56,6 +64,38 @@ var migrateNameCfg = migrateNameConfig{
 		LongHand:  "migrate-down-on-dirty",
 		ShortHand: "",
 	},
+	Up: flagName{
+		LongHand:  "up",
+		ShortHand: "",
+	},
+	Down: flagName{
+		LongHand:  "down",
+		ShortHand: "",
+	},
+	AllowOutOfOrder: flagName{
+		LongHand:  "allow-out-of-order",
+		ShortHand: "",
+	},
+	SkipTags: flagName{
+		LongHand:  "skip-tags",
+		ShortHand: "",
+	},
+	OnlyTags: flagName{
+		LongHand:  "only-tags",
+		ShortHand: "",
+	},
+	Verbose: flagName{
+		LongHand:  "verbose",
+		ShortHand: "v",
+	},
+	MetricsFile: flagName{
+		LongHand:  "metrics-file",
+		ShortHand: "",
+	},
+	SnapshotFile: flagName{
+		LongHand:  "snapshot-file",
+		ShortHand: "",
+	},
 }
 
 // migrateCmd represents the migrate command
@@ -72,16 +112,64 @@ var migrateCmd = &cobra.Command{
 	`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		targetVersion, _ := cmd.Flags().GetInt(migrateNameCfg.TargetVersion.LongHand)
-		migrationDir, _ := cmd.Flags().GetString(migrateNameCfg.LogFile.LongHand)
+		migrationDir, _ := cmd.Flags().GetString(migrateNameCfg.MigrationsDir.LongHand)
 		migrationsProtocol, _ := cmd.Flags().GetString(migrateNameCfg.MigrationsProtocol.LongHand)
+		upSteps, _ := cmd.Flags().GetInt(migrateNameCfg.Up.LongHand)
+		downSteps, _ := cmd.Flags().GetInt(migrateNameCfg.Down.LongHand)
+		skipTags, _ := cmd.Flags().GetStringSlice(migrateNameCfg.SkipTags.LongHand)
+		onlyTags, _ := cmd.Flags().GetStringSlice(migrateNameCfg.OnlyTags.LongHand)
+
+		// Only override config and target settings if flags are actually passed
+		if cmd.Flags().Changed(migrateNameCfg.RollbackOnFailure.LongHand) {
+			globalApp.MigrateFlags.RollbackOnFailure, _ = cmd.Flags().GetBool(migrateNameCfg.RollbackOnFailure.LongHand)
+		}
+		if cmd.Flags().Changed(migrateNameCfg.ResetDirtyFlag.LongHand) {
+			globalApp.MigrateFlags.ResetDirtyFlag, _ = cmd.Flags().GetBool(migrateNameCfg.ResetDirtyFlag.LongHand)
+		}
 
-		globalApp.MigrateFlags.RollbackOnFailure, _ = cmd.Flags().GetBool(migrateNameCfg.LogFile.LongHand)
-		globalApp.MigrateFlags.ResetDirtyFlag, _ = cmd.Flags().GetBool(migrateNameCfg.ResetDirtyFlag.LongHand)
+		if allowOutOfOrder, _ := cmd.Flags().GetBool(migrateNameCfg.AllowOutOfOrder.LongHand); allowOutOfOrder {
+			globalApp.MigrateFlags.AllowOutOfOrder = allowOutOfOrder
+		}
 		//globalApp.MigrateFlags.MigrateDownIfDirty, _ = cmd.Flags().GetBool(migrateNameCfg.MigrateDownOnDirty.LongHand)
 
+		if upSteps < 0 || downSteps < 0 {
+			return fmt.Errorf("--up and --down must be positive numbers")
+		}
+		if upSteps > 0 && downSteps > 0 {
+			return fmt.Errorf("--up and --down can't be set together")
+		}
+		if (upSteps > 0 || downSteps > 0) && targetVersion != -1 {
+			return fmt.Errorf("--target-version can't be set with --up or --down")
+		}
+
+		if len(skipTags) > 0 && len(onlyTags) > 0 {
+			return fmt.Errorf("--skip-tags and --only-tags can't be set together")
+		}
+
 		if targetVersion != -1 {
 			globalApp.MigrateFlags.TargetVersion = targetVersion
 		}
+		if len(skipTags) > 0 {
+			globalApp.MigrateFlags.SkipTags = skipTags
+		}
+		if len(onlyTags) > 0 {
+			globalApp.MigrateFlags.OnlyTags = onlyTags
+		}
+		if verbose, _ := cmd.Flags().GetBool(migrateNameCfg.Verbose.LongHand); verbose {
+			globalApp.MigrateFlags.Verbose = verbose
+		}
+		if metricsFile, _ := cmd.Flags().GetString(migrateNameCfg.MetricsFile.LongHand); metricsFile != "" {
+			globalApp.MigrateFlags.MetricsFile = metricsFile
+		}
+		if snapshotFile, _ := cmd.Flags().GetString(migrateNameCfg.SnapshotFile.LongHand); snapshotFile != "" {
+			globalApp.MigrateFlags.SnapshotFile = snapshotFile
+		}
+		if upSteps > 0 {
+			globalApp.MigrateFlags.Steps = upSteps
+		}
+		if downSteps > 0 {
+			globalApp.MigrateFlags.Steps = -downSteps
+		}
 		if migrationDir != "" {
 			globalApp.MigrateFlags.MigrationsDir = migrationDir
 		}
@@ -100,9 +188,9 @@ var migrateCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		defer globalApp.DB.Close()
 		return globalApp.Migrate(
-			cdbmutil.DefaultGetMigrationFunc,
-			cdbmutil.DefaultFileMigrationFunc,
-			map[int]cdbmutil.CustomMigration{},
+			execOpts.GetMigrationFunc,
+			execOpts.FileMigrationFunc,
+			execOpts.CustomMigrations,
 		)
 	},
 }
@@ -118,9 +206,21 @@ func init() {
 		-1,
 		"Migrate to specific version",
 	)
+	migrateCmd.Flags().IntP(
+		migrateNameCfg.Up.LongHand,
+		migrateNameCfg.Up.ShortHand,
+		0,
+		"Migrate up given number of versions from current version",
+	)
+	migrateCmd.Flags().IntP(
+		migrateNameCfg.Down.LongHand,
+		migrateNameCfg.Down.ShortHand,
+		0,
+		"Migrate down given number of versions from current version",
+	)
 	migrateCmd.Flags().StringP(
-		migrateNameCfg.LogFile.LongHand,
-		migrateNameCfg.LogFile.ShortHand,
+		migrateNameCfg.MigrationsDir.LongHand,
+		migrateNameCfg.MigrationsDir.ShortHand,
 		"",
 		"Directory where migration files are located",
 	)
@@ -142,6 +242,42 @@ func init() {
 		false,
 		"When set will reset dirty flag when migrating",
 	)
+	migrateCmd.Flags().BoolP(
+		migrateNameCfg.AllowOutOfOrder.LongHand,
+		migrateNameCfg.AllowOutOfOrder.ShortHand,
+		false,
+		"When set will apply versions lower than current version that have never been applied",
+	)
+	migrateCmd.Flags().StringSliceP(
+		migrateNameCfg.SkipTags.LongHand,
+		migrateNameCfg.SkipTags.ShortHand,
+		nil,
+		"Skips migrations with any of the given tags so they can be applied later with --only-tags",
+	)
+	migrateCmd.Flags().StringSliceP(
+		migrateNameCfg.OnlyTags.LongHand,
+		migrateNameCfg.OnlyTags.ShortHand,
+		nil,
+		"Only applies previously skipped migrations with any of the given tags",
+	)
+	migrateCmd.Flags().BoolP(
+		migrateNameCfg.Verbose.LongHand,
+		migrateNameCfg.Verbose.ShortHand,
+		false,
+		"Logs and displays every statement of custom migrations along with its duration and rows affected.  File migrations are logged per file with their duration as golang-migrate runs each file as a single statement",
+	)
+	migrateCmd.Flags().StringP(
+		migrateNameCfg.MetricsFile.LongHand,
+		migrateNameCfg.MetricsFile.ShortHand,
+		"",
+		"Writes prometheus metrics of migration to given file for node_exporter textfile collector ie. cdbm.prom",
+	)
+	migrateCmd.Flags().StringP(
+		migrateNameCfg.SnapshotFile.LongHand,
+		migrateNameCfg.SnapshotFile.ShortHand,
+		"",
+		"Writes schema snapshot to given file after migrating, as json if it ends with .json, else as sql",
+	)
 	migrateCmd.Flags().BoolP(
 		migrateNameCfg.MigrateDownOnDirty.LongHand,
 		migrateNameCfg.MigrateDownOnDirty.ShortHand,
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/TravisS25/cdbm/app"
+	"github.com/spf13/cobra"
+)
+
+// pingCmd represents the ping command
+var pingCmd = &cobra.Command{
+	Use:   "ping",
+	Short: "Probes every configured database connection",
+	Long: `Connects to database set by root flags and every entry of database_config,
+displaying whether each is reachable, authenticated, its latency and server version
+
+Displays: "%-12s %s %s" as status (ok | auth failed | unreachable | error |
+config error), entry and either latency and version or error
+
+Invalid root flags connection or password that can't be resolved is displayed
+as config error and remaining entries are still probed
+
+Returns error if any connection failed
+`,
+	// Overrides root command so every entry is probed instead of connecting
+	// to the first that succeeds
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return app.Ping(rootFlagsCfg)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pingCmd)
+}
@@ -19,23 +19,41 @@ import (
 	"os"
 
 	"github.com/TravisS25/cdbm/app"
+	"github.com/TravisS25/cdbm/cdbmutil"
 	"github.com/spf13/cobra"
+
+	// Registers file:// protocol so migration files can be read from host machine
+	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
 type rootNameConfig struct {
-	DBProtocol    flagName
-	Env           flagName
-	Database      flagName
-	Host          flagName
-	User          flagName
-	Password      flagName
-	Port          flagName
-	SSLMode       flagName
-	SSLRootCert   flagName
-	SSLKey        flagName
-	SSLCert       flagName
-	SSL           flagName
-	UseFileOnFail flagName
+	DBProtocol      flagName
+	Env             flagName
+	Database        flagName
+	Host            flagName
+	User            flagName
+	Password        flagName
+	PasswordFile    flagName
+	PasswordEnv     flagName
+	PasswordCommand flagName
+	Port            flagName
+	SSLMode         flagName
+	SSLRootCert     flagName
+	SSLKey          flagName
+	SSLCert         flagName
+	SSL             flagName
+	UseFileOnFail   flagName
+	Target          flagName
+	DSN             flagName
+	ConnectRetries  flagName
+	ConnectBackoff  flagName
+	ConnectTimeout  flagName
+	MaxOpenConns    flagName
+	MaxIdleConns    flagName
+	ConnMaxLifetime flagName
+	SessionParam    flagName
+	LogLevel        flagName
+	LogFormat       flagName
 }
 
 var rootNameCfg = rootNameConfig{
@@ -63,6 +81,15 @@ var rootNameCfg = rootNameConfig{
 		LongHand:  "password",
 		ShortHand: "w",
 	},
+	PasswordFile: flagName{
+		LongHand: "password-file",
+	},
+	PasswordEnv: flagName{
+		LongHand: "password-env",
+	},
+	PasswordCommand: flagName{
+		LongHand: "password-command",
+	},
 	Port: flagName{
 		LongHand:  "port",
 		ShortHand: "p",
@@ -91,12 +118,75 @@ var rootNameCfg = rootNameConfig{
 		LongHand:  "use-file-on-fail",
 		ShortHand: "f",
 	},
+	Target: flagName{
+		LongHand: "target",
+	},
+	DSN: flagName{
+		LongHand: "dsn",
+	},
+	ConnectRetries: flagName{
+		LongHand: "connect-retries",
+	},
+	ConnectBackoff: flagName{
+		LongHand: "connect-backoff",
+	},
+	ConnectTimeout: flagName{
+		LongHand: "connect-timeout",
+	},
+	MaxOpenConns: flagName{
+		LongHand: "max-open-conns",
+	},
+	MaxIdleConns: flagName{
+		LongHand: "max-idle-conns",
+	},
+	ConnMaxLifetime: flagName{
+		LongHand: "conn-max-lifetime",
+	},
+	SessionParam: flagName{
+		LongHand: "session-param",
+	},
+	LogLevel: flagName{
+		LongHand: "log-level",
+	},
+	LogFormat: flagName{
+		LongHand: "log-format",
+	},
 }
 
 var globalApp *app.CDBM
 
 var rootFlagsCfg app.RootFlagsConfig
 
+// ExecuteOptions is config struct used to customize cdbm commands when building
+// your own cdbm binary
+type ExecuteOptions struct {
+	// CustomMigrations are custom migrations to apply along with file migrations
+	//
+	// These are merged with migrations added through cdbmutil.Register and
+	// will return error if a version is found in both
+	CustomMigrations map[int]cdbmutil.CustomMigration
+
+	// GetMigrationFunc is used to retrieve migration instance used against database
+	//
+	// Defaults to cdbmutil.DefaultGetMigrationFunc
+	GetMigrationFunc cdbmutil.GetMigrationFunc
+
+	// FileMigrationFunc is used to migrate file migrations up or down
+	//
+	// Defaults to cdbmutil.DefaultFileMigrationFunc
+	FileMigrationFunc cdbmutil.FileMigrationFunc
+
+	// DriverConfig is config struct used for migrate library based on database
+	// ie. *postgres.Config or *cockroachdb.Config
+	DriverConfig interface{}
+}
+
+// execOpts is options set by ExecuteWith used by all commands
+var execOpts = ExecuteOptions{
+	GetMigrationFunc:  cdbmutil.DefaultGetMigrationFunc,
+	FileMigrationFunc: cdbmutil.DefaultFileMigrationFunc,
+}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "cdbm",
@@ -107,6 +197,17 @@ examples and usage of using your application. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		initConfig(cmd)
+	},
+	// Closes log file and returns error if any log entry failed to be written to it
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		if globalApp == nil {
+			return nil
+		}
+
+		return globalApp.CloseLogFile()
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// RunE: func(cmd *cobra.Command, args []string) error {
@@ -117,6 +218,39 @@ to quickly create a Cobra application.`,
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	ExecuteWith(ExecuteOptions{})
+}
+
+// ExecuteWith is the same as Execute but allows custom migrations and migration
+// functions to be passed so teams can build their own cdbm binary with every
+// cdbm command, for example:
+//
+//	func main() {
+//		cdbmutil.Register(5, cdbmutil.CustomMigration{Up: up5, Down: down5})
+//		cmd.ExecuteWith(cmd.ExecuteOptions{})
+//	}
+func ExecuteWith(opts ExecuteOptions) {
+	migrations := cdbmutil.RegisteredMigrations()
+
+	for k, v := range opts.CustomMigrations {
+		if _, ok := migrations[k]; ok {
+			fmt.Printf("custom migration version '%d' passed to ExecuteWith is already registered\n", k)
+			os.Exit(1)
+		}
+
+		migrations[k] = v
+	}
+
+	execOpts.CustomMigrations = migrations
+	execOpts.DriverConfig = opts.DriverConfig
+
+	if opts.GetMigrationFunc != nil {
+		execOpts.GetMigrationFunc = opts.GetMigrationFunc
+	}
+	if opts.FileMigrationFunc != nil {
+		execOpts.FileMigrationFunc = opts.FileMigrationFunc
+	}
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -125,7 +259,6 @@ func Execute() {
 
 func init() {
 	//fmt.Printf("root init called\n")
-	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(
 		&rootFlagsCfg.DBProtocol,
@@ -133,13 +266,16 @@ func init() {
 		"",
 		"Protocol of connection string used to migrate database.  Available values: postgres | cockroachdb",
 	)
-	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.EnvVar, rootNameCfg.Env.LongHand, "", "Enviroment variable that points to config file")
+	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.EnvVar, rootNameCfg.Env.LongHand, "", "Enviroment variable that points to config file.  Multiple files separated by path list separator ie. base.yml:prod.yml are merged in order")
 	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.Database, rootNameCfg.Database.LongHand, "", "Name of database to connect to")
 	rootCmd.PersistentFlags().IntVar(&rootFlagsCfg.Port, rootNameCfg.Port.LongHand, -1, "Port of database to connect to")
 	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.Host, rootNameCfg.Host.LongHand, "", "Host of database to connect to")
 	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.User, rootNameCfg.User.LongHand, "", "User of database to connect to")
 	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.Password, rootNameCfg.Password.LongHand, "", "Password of database to connect to")
-	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.SSLMode, rootNameCfg.SSLMode.LongHand, "", "SSL mode to use when connecting to database Ex. sslmode=require")
+	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.PasswordFile, rootNameCfg.PasswordFile.LongHand, "", "File whose contents are used as password of database to connect to")
+	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.PasswordEnv, rootNameCfg.PasswordEnv.LongHand, "", "Enviroment variable whose value is used as password of database to connect to")
+	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.PasswordCommand, rootNameCfg.PasswordCommand.LongHand, "", "Shell command whose stdout is used as password of database to connect to")
+	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.SSLMode, rootNameCfg.SSLMode.LongHand, "", "SSL mode to use when connecting to database.  Available values: disable | allow | prefer | require | verify-ca | verify-full")
 	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.SSLRootCert, rootNameCfg.SSLRootCert.LongHand, "", "File path where ssl root cert is located")
 	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.SSLKey, rootNameCfg.SSLKey.LongHand, "", "File path where private key is located")
 	rootCmd.PersistentFlags().StringVar(&rootFlagsCfg.SSLCert, rootNameCfg.SSLCert.LongHand, "", "File path where ssl cert is located")
@@ -150,18 +286,107 @@ func init() {
 		false,
 		"If user enters database credentials through command line and connection fails resort to using config file credentials when this is set",
 	)
+	rootCmd.PersistentFlags().StringVar(
+		&rootFlagsCfg.Target,
+		rootNameCfg.Target.LongHand,
+		"",
+		"Name of entry in 'database_config' of config file to connect to.  Overrides in 'targets' under the same name are also applied",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&rootFlagsCfg.DSN,
+		rootNameCfg.DSN.LongHand,
+		"",
+		"Url or key/value connection string of database to connect to instead of --database, --host, --port, --user and --password.  Defaults to DATABASE_URL enviroment variable if no other connection is set",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&rootFlagsCfg.ConnectRetries,
+		rootNameCfg.ConnectRetries.LongHand,
+		0,
+		"Number of times to retry connecting to database after every connection fails",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&rootFlagsCfg.ConnectBackoff,
+		rootNameCfg.ConnectBackoff.LongHand,
+		0,
+		"Time to wait before first connection retry which doubles after each retry up to 30s.  Defaults to 1s",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&rootFlagsCfg.ConnectTimeout,
+		rootNameCfg.ConnectTimeout.LongHand,
+		0,
+		"Total time to keep retrying connection to database.  If set without --connect-retries, retries until time runs out",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&rootFlagsCfg.Session.MaxOpenConns,
+		rootNameCfg.MaxOpenConns.LongHand,
+		0,
+		"Max number of open connections to database.  Defaults to no limit",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&rootFlagsCfg.Session.MaxIdleConns,
+		rootNameCfg.MaxIdleConns.LongHand,
+		0,
+		"Max number of idle connections kept in connection pool",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&rootFlagsCfg.Session.ConnMaxLifetime,
+		rootNameCfg.ConnMaxLifetime.LongHand,
+		0,
+		"Max amount of time a connection can be reused.  Defaults to forever",
+	)
+	rootCmd.PersistentFlags().StringToStringVar(
+		&rootFlagsCfg.Session.Params,
+		rootNameCfg.SessionParam.LongHand,
+		nil,
+		"Session parameter set on every connection as key=value ie. statement_timeout=5min.  Can be repeated.  application_name defaults to cdbm",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&rootFlagsCfg.LogLevel,
+		rootNameCfg.LogLevel.LongHand,
+		"",
+		"Min level of log entries written to stderr and log file.  Available values: debug | info | warn | error.  Defaults to info",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&rootFlagsCfg.LogFormat,
+		rootNameCfg.LogFormat.LongHand,
+		"",
+		"Format of log entries.  Available values: text | json.  Defaults to text",
+	)
+}
+
+// loadConfig reads in config file and ENV variables if set without connecting
+// to database for commands that don't use database of user
+func loadConfig() {
+	var err error
+
+	if globalApp, err = app.LoadCDBM(rootFlagsCfg); err != nil {
+		fmt.Fprintf(os.Stderr, err.Error())
+		os.Exit(1)
+	}
 
-	rootCmd.MarkFlagRequired(rootNameCfg.DBProtocol.LongHand)
+	globalApp.CustomMigrations = execOpts.CustomMigrations
 }
 
-// initConfig reads in config file and ENV variables if set.
-func initConfig() {
+// initConfig reads in config file and ENV variables if set and connects
+// to database for given command
+func initConfig(cmd *cobra.Command) {
 	var err error
 
-	if globalApp, err = app.NewCDBM(rootFlagsCfg, nil); err != nil {
+	// wait command keeps retrying until its own timeout if user didn't set
+	// one by flag, enviroment variable or config file
+	if cmd == waitCmd && !cmd.Flags().Changed(rootNameCfg.ConnectTimeout.LongHand) {
+		// Config errors are reported by app.NewCDBM below
+		if cfg, err := app.GetCDBMConfig(rootFlagsCfg.EnvVar); err == nil && cfg.RootFlags.ConnectTimeout == 0 {
+			rootFlagsCfg.ConnectTimeout = waitTimeout
+		}
+	}
+
+	if globalApp, err = app.NewCDBM(rootFlagsCfg, execOpts.DriverConfig); err != nil {
 		fmt.Fprintf(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 
+	globalApp.CustomMigrations = execOpts.CustomMigrations
+
 	//fmt.Printf("%+v", globalApp.MigrateFlags)
 }
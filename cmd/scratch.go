@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"github.com/TravisS25/cdbm/cdbmutil"
+	"github.com/spf13/cobra"
+)
+
+// scratchNameConfig is flag names shared by commands that migrate scratch
+// database ie. diff, squash and verify-reversible
+type scratchNameConfig struct {
+	UtilConfig         flagName
+	MigrationsDir      flagName
+	MigrationsProtocol flagName
+}
+
+var scratchNameCfg = scratchNameConfig{
+	UtilConfig: flagName{
+		LongHand:  "util-config",
+		ShortHand: "u",
+	},
+	MigrationsDir: flagName{
+		LongHand:  "migrations-dir",
+		ShortHand: "m",
+	},
+	MigrationsProtocol: flagName{
+		LongHand:  "migrations-protocol",
+		ShortHand: "p",
+	},
+}
+
+// addScratchFlags registers flags of scratchNameCfg on given command where
+// utilConfigUsage describes what cdbmutil config is used for
+func addScratchFlags(cmd *cobra.Command, utilConfigUsage string) {
+	cmd.Flags().StringP(
+		scratchNameCfg.UtilConfig.LongHand,
+		scratchNameCfg.UtilConfig.ShortHand,
+		"",
+		utilConfigUsage,
+	)
+	cmd.Flags().StringP(
+		scratchNameCfg.MigrationsDir.LongHand,
+		scratchNameCfg.MigrationsDir.ShortHand,
+		"",
+		"Directory where migration files are located",
+	)
+	cmd.Flags().StringP(
+		scratchNameCfg.MigrationsProtocol.LongHand,
+		scratchNameCfg.MigrationsProtocol.ShortHand,
+		"",
+		"Protocol used for connecting to migrations directory",
+	)
+}
+
+// applyScratchFlags overrides settings of globalApp with flags of
+// scratchNameCfg if set, defaulting migrations protocol to file protocol
+func applyScratchFlags(cmd *cobra.Command) {
+	utilConfig, _ := cmd.Flags().GetString(scratchNameCfg.UtilConfig.LongHand)
+	migrationDir, _ := cmd.Flags().GetString(scratchNameCfg.MigrationsDir.LongHand)
+	migrationsProtocol, _ := cmd.Flags().GetString(scratchNameCfg.MigrationsProtocol.LongHand)
+
+	if utilConfig != "" {
+		globalApp.DiffFlags.UtilConfig = utilConfig
+	}
+	if migrationDir != "" {
+		globalApp.MigrateFlags.MigrationsDir = migrationDir
+	}
+	if migrationsProtocol != "" {
+		globalApp.MigrateFlags.MigrationsProtocol = cdbmutil.MigrationsProtocol(migrationsProtocol)
+	} else if globalApp.MigrateFlags.MigrationsProtocol == "" {
+		globalApp.MigrateFlags.MigrationsProtocol = cdbmutil.FileProtocol
+	}
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+type squashNameConfig struct {
+	Through flagName
+}
+
+var squashNameCfg = squashNameConfig{
+	Through: flagName{
+		LongHand:  "through",
+		ShortHand: "t",
+	},
+}
+
+// squashCmd represents the squash command
+var squashCmd = &cobra.Command{
+	Use:   "squash",
+	Short: "Squashes migrations through given version into a baseline",
+	Long: `Creates scratch database, migrates it through version set by --through and
+writes its schema dump as a single baseline up file with same version, moving
+squashed migration files to "archive" directory within migrations directory
+
+Databases already at or past --through version continue unaffected while
+fresh databases start from baseline.  Databases behind --through version must
+apply archived migrations before migrating again
+
+Scratch database is created, dumped and dropped with commands of cdbmutil
+config set by --util-config or CDBM_UTIL_CONFIG enviroment variable
+`,
+	// Overrides root command so config is loaded without connecting to
+	// database of user as only scratch database is used
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		loadConfig()
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		applyScratchFlags(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		through, _ := cmd.Flags().GetInt(squashNameCfg.Through.LongHand)
+
+		return globalApp.Squash(
+			through,
+			execOpts.GetMigrationFunc,
+			execOpts.FileMigrationFunc,
+			execOpts.CustomMigrations,
+		)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(squashCmd)
+
+	squashCmd.Flags().IntP(
+		squashNameCfg.Through.LongHand,
+		squashNameCfg.Through.ShortHand,
+		0,
+		"Last version squashed into baseline",
+	)
+	addScratchFlags(
+		squashCmd,
+		"cdbmutil config file used to create, dump and drop scratch database",
+	)
+	squashCmd.MarkFlagRequired(squashNameCfg.Through.LongHand)
+}
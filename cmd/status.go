@@ -19,6 +19,17 @@ import (
 	"github.com/spf13/cobra"
 )
 
+type statusNameConfig struct {
+	MigrationsDir flagName
+}
+
+var statusNameCfg = statusNameConfig{
+	MigrationsDir: flagName{
+		LongHand:  "migrations-dir",
+		ShortHand: "m",
+	},
+}
+
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
 	Use:   "status",
@@ -27,7 +38,17 @@ var statusCmd = &cobra.Command{
 
 If no entry, simply displays "No migration entry"
 If there is entry, will display: "migration state - version:%d / dirty:%v / dirty state:%s"
+
+If migrations directory is set, will also display versions lower than the current
+version that have never been applied
 `,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		migrationDir, _ := cmd.Flags().GetString(statusNameCfg.MigrationsDir.LongHand)
+
+		if migrationDir != "" {
+			globalApp.MigrateFlags.MigrationsDir = migrationDir
+		}
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return globalApp.Status()
 	},
@@ -35,4 +56,11 @@ If there is entry, will display: "migration state - version:%d / dirty:%v / dirt
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().StringP(
+		statusNameCfg.MigrationsDir.LongHand,
+		statusNameCfg.MigrationsDir.ShortHand,
+		"",
+		"Directory where migration files are located",
+	)
 }
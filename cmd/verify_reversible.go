@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// verifyReversibleCmd represents the verify-reversible command
+var verifyReversibleCmd = &cobra.Command{
+	Use:   "verify-reversible",
+	Short: "Verifies down migrations reverse their up migrations",
+	Long: `Creates scratch database and applies every version, file and custom, up,
+down and up again, comparing schema after down with schema before up and
+schema after up again with schema after first up
+
+Every version whose down migration doesn't faithfully reverse its up migration
+is displayed with its differences
+
+Scratch database is created and dropped with commands of cdbmutil config set by
+--util-config or CDBM_UTIL_CONFIG enviroment variable
+
+Exits with non-zero status if any version is not reversible
+`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		applyScratchFlags(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer globalApp.DB.Close()
+		return globalApp.VerifyReversible(
+			execOpts.GetMigrationFunc,
+			execOpts.FileMigrationFunc,
+			execOpts.CustomMigrations,
+		)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyReversibleCmd)
+
+	addScratchFlags(
+		verifyReversibleCmd,
+		"cdbmutil config file used to create and drop scratch database",
+	)
+}
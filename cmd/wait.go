@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type waitNameConfig struct {
+	Timeout flagName
+}
+
+var waitNameCfg = waitNameConfig{
+	Timeout: flagName{
+		LongHand:  "timeout",
+		ShortHand: "t",
+	},
+}
+
+// waitTimeout is time to keep retrying if --connect-timeout is not set
+var waitTimeout time.Duration
+
+// waitCmd represents the wait command
+var waitCmd = &cobra.Command{
+	Use:   "wait",
+	Short: "Blocks until database accepts connections",
+	Long: `Blocks until a connection to database can be established and a simple query
+succeeds, logging every failed attempt along with host that was tried
+
+Useful before running migrate in container startup scripts where database may
+still be booting
+
+Retries use --connect-retries and --connect-backoff and will stop after
+--connect-timeout or --timeout if --connect-timeout is not set
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return globalApp.Wait()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(waitCmd)
+
+	waitCmd.Flags().DurationVarP(
+		&waitTimeout,
+		waitNameCfg.Timeout.LongHand,
+		waitNameCfg.Timeout.ShortHand,
+		time.Minute,
+		"Total time to wait for database if --connect-timeout is not set",
+	)
+}